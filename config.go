@@ -0,0 +1,105 @@
+package main
+
+import (
+	"asmago/internal/app"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configEditCmd)
+	configCmd.AddCommand(configPathCmd)
+	configCmd.AddCommand(configValidateCmd)
+}
+
+// configCmd defines the 'config' subcommand.
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Read and change the settings in config.yaml.",
+	Long: `Read and change the settings in ~/.config/asmago/config.yaml: defaults,
+picker sizes, environment rules, the data directory and preferences by
+AWS profile. Keys are written with dots, e.g. 'ui.page_size' or
+'profiles.prod.region'.
+
+Every setting can be overridden with an ASMAGO_* environment variable, and
+ASMAGO_CONFIG_FILE points asmago at another file.`,
+}
+
+// configGetCmd defines the 'config get' subcommand.
+var configGetCmd = &cobra.Command{
+	Use:   "get [key]",
+	Short: "Display the value of a setting, or of every setting.",
+	Long: `Display the value in effect for a setting, after config.yaml and the
+ASMAGO_* environment variables are applied. Without a key, every setting
+is listed.`,
+	Example: `  asmago config get
+  asmago config get defaults.region`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var key string
+		if len(args) == 1 {
+			key = args[0]
+		}
+		exitOnError(app.ConfigGet(key))
+	},
+}
+
+// configSetCmd defines the 'config set' subcommand.
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a setting in config.yaml.",
+	Example: `  asmago config set ui.page_size 15
+  asmago config set profiles.prod.env prod`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(app.ConfigSet(args[0], args[1]))
+	},
+}
+
+// configEditCmd defines the 'config edit' subcommand.
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open config.yaml in $VISUAL or $EDITOR.",
+	Long: `Open config.yaml in $VISUAL or $EDITOR, creating it first from a
+template documenting every setting. The file is checked once the editor
+exits.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(app.ConfigEdit())
+	},
+}
+
+// configPathCmd defines the 'config path' subcommand.
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Display the path of config.yaml.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := app.ConfigFilePath()
+		exitOnError(err)
+		fmt.Println(path)
+	},
+}
+
+// configValidateCmd defines the 'config validate' subcommand.
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check config.yaml, rds.json, targets.json and the RDS targets of shortcuts.",
+	Long: `Check config.yaml, rds.json and targets.json and report each problem
+with its line, column and entry, and a suggested fix: JSON syntax errors,
+unknown fields, missing values, types other than read or write, ports out of
+range, duplicate key|env|type IDs and entries sharing a local port. Shortcuts
+whose RDS target no longer exists, and RDS targets defined in both rds.json
+and targets.json, are reported too.
+
+rds.json and targets.json are checked the same way whenever they are loaded.
+The command exits with status 1 if it finds an error; warnings alone do not
+fail it.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(app.ValidateConfig())
+	},
+}
@@ -2,8 +2,7 @@ package app
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"slices"
 
 	"github.com/lithammer/fuzzysearch/fuzzy"
 	"github.com/manifoldco/promptui"
@@ -12,7 +11,11 @@ import (
 // App is the central struct of the application.
 type App struct {
 	shortcutMgr *ShortcutManager
+	settings    Settings
 	DryRun      bool
+	Background  bool   // Hand port forwarding sessions to the tunnel supervisor.
+	Reconnect   bool   // Restart port forwarding sessions that drop.
+	Env         string // Environment whose targets are offered, instead of the detected one.
 }
 
 // NewApp is the constructor for creating a new application instance.
@@ -27,11 +30,12 @@ func NewApp(dryRun bool) (*App, error) {
 		return nil, err
 	}
 
-	shortcutMgr, err := newShortcutManager()
+	settings := loadSettings()
+	shortcutMgr, err := newShortcutManager(settings.ShortcutLimit)
 	if err != nil {
 		return nil, err
 	}
-	return &App{shortcutMgr: shortcutMgr, DryRun: dryRun}, nil
+	return &App{shortcutMgr: shortcutMgr, settings: settings, DryRun: dryRun, Reconnect: settings.Reconnect}, nil
 }
 
 // Run is the main entry point for running the application.
@@ -41,7 +45,7 @@ func (a *App) Run() error {
 	var selectedShortcut *Shortcut
 	if len(shortcutList) > 0 {
 		displayItems = append(displayItems, manualFlowChoice)
-		prompt := promptui.Select{Label: "Select Shortcut or Run Manual Flow", Items: displayItems, Size: 10}
+		prompt := promptui.Select{Label: "Select Shortcut or Run Manual Flow", Items: displayItems, Size: a.settings.PageSize}
 		index, result, err := prompt.Run()
 		if err != nil {
 			fmt.Println("Process cancelled.")
@@ -58,37 +62,41 @@ func (a *App) Run() error {
 	return a.runManualFlow()
 }
 
-// ListShortcuts displays the most frequently and last used shortcuts.
-func (a *App) ListShortcuts() error {
-	shortcutList, _ := a.shortcutMgr.getDisplayList()
-	if len(shortcutList) == 0 {
-		fmt.Println(Yellow("No shortcuts found."))
-		return nil
+// RunShortcut runs the shortcut saved under the given name.
+func (a *App) RunShortcut(name string) error {
+	sc, ok := a.shortcutMgr.findByName(name)
+	if !ok {
+		return newExitError(ExitNotFound, "no shortcut named '%s'; see 'asmago shortcuts'", name)
 	}
+	return a.executeShortcut(sc)
+}
 
-	fmt.Println(Cyan("Top 5 Shortcuts:"))
-	for i, sc := range shortcutList {
-		remark := ""
-		if i == 0 && a.shortcutMgr.lastUsedKey != "" && sc.DisplayString == a.shortcutMgr.shortcuts[a.shortcutMgr.lastUsedKey].DisplayString {
-			remark = Yellow(" (last used)")
-		}
-
-		fmt.Printf(" %d. %s%s\n", i+1, sc.DisplayString, remark)
+// ShortcutNames returns the names of all saved shortcuts. It reads the data
+// directly so it can be used for shell completion without initializing the app.
+func ShortcutNames() []string {
+	shortcuts, err := loadShortcuts()
+	if err != nil {
+		return nil
 	}
-	return nil
+	sm := &ShortcutManager{shortcuts: shortcuts}
+	return sm.names()
 }
 
 // executeShortcut runs the workflow based on a selected shortcut.
 func (a *App) executeShortcut(sc *Shortcut) error {
 	fmt.Printf(Cyan("--- Running Shortcut: %s ---\n"), sc.DisplayString)
-	region, err := getRegionForProfile(sc.Profile)
+	region, err := getRegionForProfile(sc.Profile, a.settings)
 	if err != nil {
 		return fmt.Errorf("failed to get region for shortcut: %w", err)
 	}
 
+	if err := a.resolveShortcutInstance(sc, region); err != nil {
+		return err
+	}
+
 	a.shortcutMgr.addOrUpdate(*sc)
 
-	if err := executeFinalAction(sc, region, a.DryRun); err != nil {
+	if err := a.executeFinalAction(sc, region); err != nil {
 		return fmt.Errorf("failed to execute shortcut: %w", err)
 	}
 
@@ -102,13 +110,7 @@ func (a *App) executeShortcut(sc *Shortcut) error {
 func (a *App) runManualFlow() error {
 	fmt.Println(Cyan("--- Running Manual Flow ---"))
 
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("failed to get home directory: %w", err)
-	}
-	configPath := filepath.Join(homeDir, ".aws", "config")
-
-	profiles, err := getAWSProfiles(configPath)
+	profiles, err := listAWSProfiles()
 	if err != nil {
 		return err
 	}
@@ -116,13 +118,15 @@ func (a *App) runManualFlow() error {
 		return fmt.Errorf("no AWS profiles found")
 	}
 
-	promptSelectProfile := promptui.Select{Label: "Select AWS Profile", Items: profiles, Searcher: func(input string, index int) bool { return fuzzy.Match(input, profiles[index]) }}
+	// Start on the profile selected by AWS_PROFILE, if any.
+	cursor := max(slices.Index(profiles, defaultProfile()), 0)
+	promptSelectProfile := promptui.Select{Label: "Select AWS Profile", Items: profiles, CursorPos: cursor, Size: a.settings.PageSize, Searcher: func(input string, index int) bool { return fuzzy.Match(input, profiles[index]) }}
 	_, selectedProfile, err := promptSelectProfile.Run()
 	if err != nil {
 		return fmt.Errorf("selection cancelled")
 	}
 
-	selectedRegion, err := getRegionForProfile(selectedProfile)
+	selectedRegion, err := getRegionForProfile(selectedProfile, a.settings)
 	if err != nil {
 		return err
 	}
@@ -130,7 +134,7 @@ func (a *App) runManualFlow() error {
 	fmt.Printf("Using Profile: %s, Region: %s\n", selectedProfile, selectedRegion)
 	fmt.Println("-------------------------------------")
 
-	selectedInstance, err := getAndSelectInstance(selectedProfile, selectedRegion, 0)
+	selectedInstance, err := getAndSelectInstance(selectedProfile, selectedRegion, a.settings)
 	if err != nil {
 		return err
 	}
@@ -139,24 +143,26 @@ func (a *App) runManualFlow() error {
 		return nil
 	}
 
-	instanceNameStr := *selectedInstance.Name
-	if selectedInstance.Name == nil || *selectedInstance.Name == "" {
-		instanceNameStr = selectedInstance.ID
-	}
+	instanceNameStr := selectedInstance.displayName()
 	fmt.Println("-------------------------------------")
 	fmt.Printf("✅ Instance Selected: %s (%s)\n", instanceNameStr, selectedInstance.ID)
 	fmt.Println("-------------------------------------")
 
-	actionItems := []string{"Start Session (SSM)", "Connect RDS"}
-	actionPrompt := promptui.Select{Label: "Select Action", Items: actionItems, Searcher: func(input string, index int) bool { return fuzzy.Match(input, actionItems[index]) }}
+	actionItems := []string{actionSSM, actionRDS, actionForward, actionInstancePort, actionRunCommand}
+	actionPrompt := promptui.Select{Label: "Select Action", Items: actionItems, Size: a.settings.PageSize, Searcher: func(input string, index int) bool { return fuzzy.Match(input, actionItems[index]) }}
 	_, selectedAction, err := actionPrompt.Run()
 	if err != nil {
 		return fmt.Errorf("selection cancelled")
 	}
 
+	var env string
+	if selectedAction == actionRDS || selectedAction == actionForward {
+		env = targetEnv(a.settings, selectedInstance, selectedProfile, a.Env)
+	}
+
 	var rdsID string
-	if selectedAction == "Connect RDS" {
-		rdsConfig, err := handleRDSSelection(selectedInstance)
+	if selectedAction == actionRDS {
+		rdsConfig, err := handleRDSSelection(env, selectedProfile, selectedRegion, a.settings)
 		if err != nil {
 			return err
 		}
@@ -164,7 +170,38 @@ func (a *App) runManualFlow() error {
 			fmt.Println(Yellow("\nProcess aborted by user."))
 			return nil
 		}
-		rdsID = fmt.Sprintf("%s|%s|%s", rdsConfig.Key, rdsConfig.Env, rdsConfig.Type)
+		rdsID = rdsConfig.ID()
+	}
+
+	var targetID string
+	if selectedAction == actionForward {
+		target, err := handleTargetSelection(env, selectedProfile, selectedRegion, a.settings)
+		if err != nil {
+			return err
+		}
+		if target == nil {
+			fmt.Println(Yellow("\nProcess aborted by user."))
+			return nil
+		}
+		targetID = target.ID()
+	}
+
+	var portPairs []PortPair
+	if selectedAction == actionInstancePort {
+		portPairs = promptPortPairs()
+		if portPairs == nil {
+			fmt.Println(Yellow("\nProcess aborted by user."))
+			return nil
+		}
+	}
+
+	var command string
+	if selectedAction == actionRunCommand {
+		command = promptCommand()
+		if command == "" {
+			fmt.Println(Yellow("\nProcess aborted by user."))
+			return nil
+		}
 	}
 
 	shortcut := Shortcut{
@@ -173,10 +210,18 @@ func (a *App) runManualFlow() error {
 		InstanceName: instanceNameStr,
 		Action:       selectedAction,
 		RDS_ID:       rdsID,
+		TargetID:     targetID,
+		PortPairs:    portPairs,
+		Command:      command,
+
+		AutoScalingGroup: selectedInstance.autoScalingGroup(),
+	}
+	if !a.shortcutMgr.exists(shortcut) {
+		shortcut.Name = promptShortcutName(a.shortcutMgr, shortcutKey(shortcut))
 	}
 
 	a.shortcutMgr.addOrUpdate(shortcut)
 	fmt.Println(Green("✅ Scenario successfully saved/updated as a shortcut."))
 
-	return executeFinalAction(&shortcut, selectedRegion, a.DryRun)
+	return a.executeFinalAction(&shortcut, selectedRegion)
 }
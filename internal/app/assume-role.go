@@ -0,0 +1,338 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/manifoldco/promptui"
+)
+
+// Temporary role credentials are reused until roleCredentialsMargin before
+// they expire, and requested for defaultRoleDuration unless the profile sets
+// duration_seconds.
+const (
+	roleCredentialsMargin = 5 * time.Minute
+	defaultRoleDuration   = time.Hour
+)
+
+// roleCredentials are the temporary credentials of an assume-role profile,
+// as cached in role_credentials.json.
+type roleCredentials struct {
+	RoleARN         string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// roleCredentialsMu guards the role credentials cache file. It is only held
+// while the file is read or written, never during a call to AWS.
+var roleCredentialsMu sync.Mutex
+
+// roleAssumeLocks holds a mutex per profile that serializes its role
+// assumption, so that concurrent calls share one MFA prompt.
+var roleAssumeLocks sync.Map
+
+// mfaPromptUnavailable is set when standard input and output are not the
+// user's, as under 'proxy', so that MFA codes cannot be asked for.
+var mfaPromptUnavailable bool
+
+// isAssumeRoleProfile reports whether a profile assumes a role with the
+// credentials of a source profile.
+func isAssumeRoleProfile(profile string) bool {
+	cfg, err := loadProfileConfig(profile)
+	return err == nil && cfg.RoleARN != "" && cfg.SourceProfile != ""
+}
+
+// assumeRoleCredentials returns temporary credentials for an assume-role
+// profile, from the cache when they are still valid. Otherwise the role is
+// assumed with the credentials of the source profile, which may itself assume
+// a role, after prompting for the MFA code if the profile sets mfa_serial.
+func assumeRoleCredentials(profile string) (roleCredentials, error) {
+	cfg, err := loadProfileConfig(profile)
+	if err != nil {
+		return roleCredentials{}, err
+	}
+	if err := checkRoleChain(cfg); err != nil {
+		return roleCredentials{}, err
+	}
+	if creds, ok, err := cachedRoleCredentials(profile, cfg.RoleARN); err != nil || ok {
+		return creds, err
+	}
+
+	lock, _ := roleAssumeLocks.LoadOrStore(profile, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	// Another call may have assumed the role while this one waited.
+	if creds, ok, err := cachedRoleCredentials(profile, cfg.RoleARN); err != nil || ok {
+		return creds, err
+	}
+
+	duration := defaultRoleDuration
+	if value := cfg.Properties["duration_seconds"]; value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil {
+			return roleCredentials{}, fmt.Errorf("invalid duration_seconds '%s' in profile '%s'", value, profile)
+		}
+		duration = time.Duration(seconds) * time.Second
+	}
+	input := &sts.AssumeRoleInput{
+		RoleArn:         aws.String(cfg.RoleARN),
+		RoleSessionName: aws.String(roleSessionName(cfg)),
+		DurationSeconds: aws.Int32(int32(duration.Seconds())),
+	}
+	if externalID := cfg.Properties["external_id"]; externalID != "" {
+		input.ExternalId = aws.String(externalID)
+	}
+	if cfg.MFASerial != "" {
+		if mfaPromptUnavailable {
+			return roleCredentials{}, newExitError(ExitAWS, "profile '%s' needs an MFA code, which cannot be asked for here; run 'asmago login %s' once to cache its role credentials", profile, profile)
+		}
+		code, err := promptMFACode(profile, cfg.MFASerial)
+		if err != nil {
+			return roleCredentials{}, err
+		}
+		input.SerialNumber = aws.String(cfg.MFASerial)
+		input.TokenCode = aws.String(code)
+	}
+
+	region := profileRegion(profile)
+	if region == "" {
+		region = profileRegion(cfg.SourceProfile)
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	// In a chain, the configuration of the source profile resolves its own
+	// role through this function, under the lock of the source profile.
+	var creds roleCredentials
+	err = callAWS(cfg.SourceProfile, region, func(ctx context.Context, awsCfg aws.Config) error {
+		output, err := sts.NewFromConfig(awsCfg).AssumeRole(ctx, input)
+		if err != nil {
+			return err
+		}
+		creds = roleCredentials{
+			RoleARN:         cfg.RoleARN,
+			AccessKeyID:     aws.ToString(output.Credentials.AccessKeyId),
+			SecretAccessKey: aws.ToString(output.Credentials.SecretAccessKey),
+			SessionToken:    aws.ToString(output.Credentials.SessionToken),
+			Expiration:      aws.ToTime(output.Credentials.Expiration),
+		}
+		return nil
+	})
+	if err != nil {
+		return roleCredentials{}, withExitCode(ExitAWS, fmt.Errorf("failed to assume role %s for profile '%s': %w", cfg.RoleARN, profile, err))
+	}
+
+	if err := storeRoleCredentials(profile, creds); err != nil {
+		fmt.Fprintf(os.Stderr, Yellow("Warning: %v\n"), err)
+	}
+	fmt.Fprintf(os.Stderr, Green("🔐 Assumed role %s for profile '%s' until %s\n"), cfg.RoleARN, profile, creds.Expiration.Local().Format("15:04"))
+	return creds, nil
+}
+
+// cachedRoleCredentials returns the cached credentials of a profile when they
+// are for roleARN and remain valid for more than roleCredentialsMargin.
+func cachedRoleCredentials(profile, roleARN string) (roleCredentials, bool, error) {
+	roleCredentialsMu.Lock()
+	defer roleCredentialsMu.Unlock()
+	cache, err := loadRoleCredentialsCache()
+	if err != nil {
+		return roleCredentials{}, false, err
+	}
+	creds, ok := cache[profile]
+	if !ok || creds.RoleARN != roleARN || time.Until(creds.Expiration) <= roleCredentialsMargin {
+		return roleCredentials{}, false, nil
+	}
+	return creds, true, nil
+}
+
+// storeRoleCredentials adds the credentials of a profile to the cache.
+func storeRoleCredentials(profile string, creds roleCredentials) error {
+	roleCredentialsMu.Lock()
+	defer roleCredentialsMu.Unlock()
+	cache, err := loadRoleCredentialsCache()
+	if err != nil {
+		return err
+	}
+	cache[profile] = creds
+	return saveRoleCredentialsCache(cache)
+}
+
+// checkRoleChain reports an error if the source_profile chain of a profile
+// is missing a profile or loops.
+func checkRoleChain(cfg *awsProfile) error {
+	seen := map[string]bool{cfg.Name: true}
+	for cfg.SourceProfile != "" && cfg.RoleARN != "" {
+		if seen[cfg.SourceProfile] {
+			return fmt.Errorf("source_profile of profile '%s' loops back to '%s'", cfg.Name, cfg.SourceProfile)
+		}
+		seen[cfg.SourceProfile] = true
+		next, err := loadProfileConfig(cfg.SourceProfile)
+		if err != nil {
+			return fmt.Errorf("source_profile of profile '%s': %w", cfg.Name, err)
+		}
+		cfg = next
+	}
+	return nil
+}
+
+// roleSessionName returns the role_session_name of a profile, or a name
+// identifying asmago and the local user.
+func roleSessionName(cfg *awsProfile) string {
+	if name := cfg.Properties["role_session_name"]; name != "" {
+		return name
+	}
+	name := "asmago"
+	if user := os.Getenv("USER"); user != "" {
+		name += "-" + user
+	}
+	return name
+}
+
+// promptMFACode asks for the current code of an MFA device.
+func promptMFACode(profile, serial string) (string, error) {
+	prompt := promptui.Prompt{
+		Label:  fmt.Sprintf("MFA code for profile '%s' (%s)", profile, serial),
+		Stdout: os.Stderr,
+		Validate: func(input string) error {
+			if len(input) != 6 {
+				return fmt.Errorf("the code has 6 digits")
+			}
+			if _, err := strconv.Atoi(input); err != nil {
+				return fmt.Errorf("the code has 6 digits")
+			}
+			return nil
+		},
+	}
+	code, err := prompt.Run()
+	if err != nil {
+		return "", fmt.Errorf("MFA code entry cancelled")
+	}
+	return code, nil
+}
+
+// forgetRoleCredentials drops the cached credentials of a profile, so that
+// the role is assumed again.
+func forgetRoleCredentials(profile string) error {
+	roleCredentialsMu.Lock()
+	defer roleCredentialsMu.Unlock()
+	cache, err := loadRoleCredentialsCache()
+	if err != nil {
+		return err
+	}
+	if _, ok := cache[profile]; !ok {
+		return nil
+	}
+	delete(cache, profile)
+	return saveRoleCredentialsCache(cache)
+}
+
+// roleCredentialsPath returns the path of the role credentials cache.
+func roleCredentialsPath() (string, error) {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, "role_credentials.json"), nil
+}
+
+// loadRoleCredentialsCache reads the cached role credentials by profile.
+func loadRoleCredentialsCache() (map[string]roleCredentials, error) {
+	cache := make(map[string]roleCredentials)
+	path, err := roleCredentialsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		// A corrupted cache only costs a new role assumption.
+		return make(map[string]roleCredentials), nil
+	}
+	return cache, nil
+}
+
+// saveRoleCredentialsCache writes the role credentials cache, readable only
+// by the user. Expired credentials are dropped.
+func saveRoleCredentialsCache(cache map[string]roleCredentials) error {
+	for profile, creds := range cache {
+		if time.Now().After(creds.Expiration) {
+			delete(cache, profile)
+		}
+	}
+	path, err := roleCredentialsPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := writeFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to cache role credentials: %w", err)
+	}
+	return nil
+}
+
+// roleCredentialsProvider provides the credentials of an assume-role profile
+// to the SDK.
+type roleCredentialsProvider struct {
+	profile string
+}
+
+func (p roleCredentialsProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	creds, err := assumeRoleCredentials(p.profile)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	return aws.Credentials{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		Source:          "asmago",
+		CanExpire:       true,
+		Expires:         creds.Expiration,
+	}, nil
+}
+
+// roleCredentialsEnv returns the environment for an AWS CLI child running
+// with the credentials of an assume-role profile. The AWS_PROFILE variables
+// are removed so the CLI uses the credentials as they are.
+func roleCredentialsEnv(profile string) ([]string, error) {
+	creds, err := assumeRoleCredentials(profile)
+	if err != nil {
+		return nil, err
+	}
+	var env []string
+	for _, kv := range os.Environ() {
+		switch name, _, _ := strings.Cut(kv, "="); name {
+		case "AWS_PROFILE", "AWS_DEFAULT_PROFILE", "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN":
+			continue
+		}
+		env = append(env, kv)
+	}
+	return append(env,
+		"AWS_ACCESS_KEY_ID="+creds.AccessKeyID,
+		"AWS_SECRET_ACCESS_KEY="+creds.SecretAccessKey,
+		"AWS_SESSION_TOKEN="+creds.SessionToken,
+	), nil
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/smithy-go"
+)
+
+// awsFailure is the cause of a failed AWS API call or AWS CLI command.
+type awsFailure int
+
+const (
+	failureUnknown            awsFailure = iota
+	failureExpiredToken                  // The SSO token or session credentials expired.
+	failureNoCredentials                 // No credentials, or credentials AWS does not accept.
+	failureAccessDenied                  // The credentials lack a permission.
+	failureThrottled                     // AWS rejected the request because of its rate.
+	failureTargetNotConnected            // The instance is unknown to or unreachable by SSM.
+	failureMFARejected                   // STS rejected the MFA code of an assume-role profile.
+)
+
+// failurePatterns maps error codes and messages of the AWS CLI, the Session
+// Manager plugin and the SDK to failures. They are checked in order, so
+// token problems win over the generic credential messages that accompany them.
+var failurePatterns = []struct {
+	failure  awsFailure
+	patterns []string
+}{
+	{failureExpiredToken, []string{
+		"ExpiredToken", // Also matches ExpiredTokenException.
+		"UnauthorizedException",
+		"InvalidGrantException",
+		"Token has expired",
+		"Error loading SSO Token",
+		"Error when retrieving token from sso",
+		"session associated with this profile has expired",
+		"cached SSO token",
+	}},
+	{failureNoCredentials, []string{
+		"Unable to locate credentials",
+		"InvalidClientTokenId",
+		"UnrecognizedClientException",
+		"AuthFailure",
+		"SignatureDoesNotMatch",
+		"no EC2 IMDS role found",
+		"failed to retrieve credentials",
+		"The config profile",
+	}},
+	{failureMFARejected, []string{
+		"MultiFactorAuthentication failed",
+	}},
+	{failureAccessDenied, []string{
+		"AccessDenied", // Also matches AccessDeniedException.
+		"UnauthorizedOperation",
+		"is not authorized to perform",
+	}},
+	{failureThrottled, []string{
+		"Throttling", // Also matches ThrottlingException.
+		"ThrottledException",
+		"RequestLimitExceeded",
+		"TooManyRequests",
+		"Rate exceeded",
+	}},
+	{failureTargetNotConnected, []string{
+		"TargetNotConnected",
+		"InvalidInstanceId",
+		"InvalidTarget",
+		"is not connected",
+	}},
+}
+
+// classifyAWSOutput returns the failure described by the standard error of
+// an AWS CLI command.
+func classifyAWSOutput(stderr string) awsFailure {
+	lower := strings.ToLower(stderr)
+	for _, fp := range failurePatterns {
+		for _, pattern := range fp.patterns {
+			if strings.Contains(lower, strings.ToLower(pattern)) {
+				return fp.failure
+			}
+		}
+	}
+	return failureUnknown
+}
+
+// classifyAWSError returns the failure behind an SDK error.
+func classifyAWSError(err error) awsFailure {
+	var tokenErr *ssocreds.InvalidTokenError
+	if errors.As(err, &tokenErr) {
+		return failureExpiredToken
+	}
+	var profileErr config.SharedConfigProfileNotExistError
+	if errors.As(err, &profileErr) {
+		return failureNoCredentials
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		if failure := classifyAWSOutput(apiErr.ErrorCode() + ": " + apiErr.ErrorMessage()); failure != failureUnknown {
+			return failure
+		}
+	}
+	return classifyAWSOutput(err.Error())
+}
+
+// awsError is an AWS failure explained to the user.
+type awsError struct {
+	Failure awsFailure
+	Profile string
+	Detail  string // Message from AWS, shown after the explanation.
+	Err     error  // Underlying SDK error, if any.
+}
+
+// newAWSCLIError explains a failed AWS CLI command from its standard error.
+func newAWSCLIError(profile, stderr string, err error) error {
+	return &awsError{Failure: classifyAWSOutput(stderr), Profile: profile, Detail: lastLine(stderr), Err: err}
+}
+
+func (e *awsError) Error() string {
+	detail := e.Detail
+	var apiErr smithy.APIError
+	if detail == "" && errors.As(e.Err, &apiErr) {
+		detail = fmt.Sprintf("%s: %s", apiErr.ErrorCode(), apiErr.ErrorMessage())
+	} else if detail == "" && e.Err != nil {
+		detail = e.Err.Error()
+	}
+
+	var msg string
+	switch e.Failure {
+	case failureExpiredToken:
+		msg = fmt.Sprintf("the credentials of profile '%s' have expired; log in again and retry", e.Profile)
+	case failureNoCredentials:
+		msg = fmt.Sprintf("no valid AWS credentials for profile '%s'; check the profile in ~/.aws/config and ~/.aws/credentials", e.Profile)
+	case failureAccessDenied:
+		msg = fmt.Sprintf("access denied for profile '%s': its role lacks a permission this action needs", e.Profile)
+	case failureMFARejected:
+		msg = fmt.Sprintf("the MFA code for profile '%s' was rejected; try again with a fresh code", e.Profile)
+	case failureThrottled:
+		msg = fmt.Sprintf("AWS is throttling requests for profile '%s'; wait a moment and try again", e.Profile)
+	case failureTargetNotConnected:
+		msg = "the instance is not connected to Systems Manager; check that it is running, that the SSM agent is up and that its instance profile allows SSM"
+	default:
+		if detail == "" {
+			return "AWS CLI command failed"
+		}
+		return detail
+	}
+	if detail == "" {
+		return msg
+	}
+	return fmt.Sprintf("%s (%s)", msg, detail)
+}
+
+func (e *awsError) Unwrap() error {
+	return e.Err
+}
+
+// lastLine returns the last non-empty line of s, trimmed.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/smithy-go"
+)
+
+func TestClassifyAWSOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr string
+		want   awsFailure
+	}{
+		{
+			name:   "expired session credentials",
+			stderr: "\nAn error occurred (ExpiredTokenException) when calling the DescribeInstances operation: The security token included in the request is expired\n",
+			want:   failureExpiredToken,
+		},
+		{
+			name:   "expired SSO token",
+			stderr: "Error when retrieving token from sso: Token has expired and refresh failed\n",
+			want:   failureExpiredToken,
+		},
+		{
+			name:   "expired SSO session before the generic credential message",
+			stderr: "The SSO session associated with this profile has expired or is otherwise invalid. To refresh this SSO session run aws sso login with the corresponding profile.\nUnable to locate credentials. You can configure credentials by running \"aws configure\".\n",
+			want:   failureExpiredToken,
+		},
+		{
+			name:   "no credentials",
+			stderr: "\nUnable to locate credentials. You can configure credentials by running \"aws configure\".\n",
+			want:   failureNoCredentials,
+		},
+		{
+			name:   "unrecognized client",
+			stderr: "\nAn error occurred (UnrecognizedClientException) when calling the StartSession operation: The security token included in the request is invalid.\n",
+			want:   failureNoCredentials,
+		},
+		{
+			name:   "invalid access key",
+			stderr: "\nAn error occurred (InvalidClientTokenId) when calling the GetCallerIdentity operation: The security token included in the request is invalid.\n",
+			want:   failureNoCredentials,
+		},
+		{
+			name:   "missing profile",
+			stderr: "\nThe config profile (staging) could not be found\n",
+			want:   failureNoCredentials,
+		},
+		{
+			name:   "MFA code rejected before access denied",
+			stderr: "\nAn error occurred (AccessDenied) when calling the AssumeRole operation: MultiFactorAuthentication failed with invalid MFA one time pass code. \n",
+			want:   failureMFARejected,
+		},
+		{
+			name:   "access denied",
+			stderr: "\nAn error occurred (AccessDeniedException) when calling the StartSession operation: User: arn:aws:sts::123456789012:assumed-role/Dev/me is not authorized to perform: ssm:StartSession on resource: arn:aws:ec2:ap-southeast-1:123456789012:instance/i-0123456789abcdef0\n",
+			want:   failureAccessDenied,
+		},
+		{
+			name:   "EC2 unauthorized operation",
+			stderr: "\nAn error occurred (UnauthorizedOperation) when calling the DescribeInstances operation: You are not authorized to perform this operation.\n",
+			want:   failureAccessDenied,
+		},
+		{
+			name:   "throttled",
+			stderr: "\nAn error occurred (ThrottlingException) when calling the DescribeInstanceInformation operation (reached max retries: 2): Rate exceeded\n",
+			want:   failureThrottled,
+		},
+		{
+			name:   "target not connected",
+			stderr: "\nAn error occurred (TargetNotConnected) when calling the StartSession operation: i-0123456789abcdef0 is not connected.\n",
+			want:   failureTargetNotConnected,
+		},
+		{
+			name:   "invalid instance for Run Command",
+			stderr: "\nAn error occurred (InvalidInstanceId) when calling the SendCommand operation: Instances [[i-0123456789abcdef0]] not in a valid state for account 123456789012\n",
+			want:   failureTargetNotConnected,
+		},
+		{
+			name:   "unknown failure",
+			stderr: "\nConnection was closed before we received a valid response from endpoint URL: \"https://ssm.ap-southeast-1.amazonaws.com/\".\n",
+			want:   failureUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyAWSOutput(tt.stderr); got != tt.want {
+				t.Errorf("classifyAWSOutput() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyAWSError(t *testing.T) {
+	// apiError wraps an API error the way the SDK reports a failed operation.
+	apiError := func(service, operation, code, message string) error {
+		return fmt.Errorf("operation error %s: %s, https response error StatusCode: 400, RequestID: 1b2c, %w", service, operation,
+			&smithy.GenericAPIError{Code: code, Message: message})
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want awsFailure
+	}{
+		{
+			name: "invalid SSO token",
+			err:  fmt.Errorf("failed to refresh cached credentials, %w", &ssocreds.InvalidTokenError{Err: errors.New("the SSO session has expired or is invalid")}),
+			want: failureExpiredToken,
+		},
+		{
+			name: "missing profile",
+			err:  config.SharedConfigProfileNotExistError{Profile: "staging", Err: errors.New("section 'profile staging' does not exist")},
+			want: failureNoCredentials,
+		},
+		{
+			name: "expired session credentials",
+			err:  apiError("EC2", "DescribeInstances", "ExpiredTokenException", "The security token included in the request is expired"),
+			want: failureExpiredToken,
+		},
+		{
+			name: "unrecognized client",
+			err:  apiError("SSM", "StartSession", "UnrecognizedClientException", "The security token included in the request is invalid."),
+			want: failureNoCredentials,
+		},
+		{
+			name: "MFA code rejected",
+			err:  apiError("STS", "AssumeRole", "AccessDenied", "MultiFactorAuthentication failed with invalid MFA one time pass code."),
+			want: failureMFARejected,
+		},
+		{
+			name: "access denied",
+			err:  apiError("SSM", "SendCommand", "AccessDeniedException", "User: arn:aws:sts::123456789012:assumed-role/Dev/me is not authorized to perform: ssm:SendCommand"),
+			want: failureAccessDenied,
+		},
+		{
+			name: "throttled",
+			err:  apiError("SSM", "DescribeInstanceInformation", "ThrottlingException", "Rate exceeded"),
+			want: failureThrottled,
+		},
+		{
+			name: "invalid instance",
+			err:  apiError("SSM", "SendCommand", "InvalidInstanceId", "Instances [[i-0123456789abcdef0]] not in a valid state for account 123456789012"),
+			want: failureTargetNotConnected,
+		},
+		{
+			name: "no credential source",
+			err:  errors.New("operation error EC2: DescribeInstances, get identity: get credentials: failed to refresh cached credentials, no EC2 IMDS role found, operation error ec2imds: GetMetadata, request canceled"),
+			want: failureNoCredentials,
+		},
+		{
+			name: "unknown API error",
+			err:  apiError("EC2", "DescribeInstances", "InternalError", "An internal error has occurred."),
+			want: failureUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyAWSError(tt.err); got != tt.want {
+				t.Errorf("classifyAWSError() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
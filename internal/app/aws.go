@@ -1,55 +1,32 @@
 package app
 
 import (
-	"bufio"
 	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
-	"regexp"
-	"sort"
 	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/manifoldco/promptui"
 )
 
-// getAWSProfiles reads the ~/.aws/config file and returns a list of available profiles.
-func getAWSProfiles(configPath string) ([]string, error) {
-	file, err := os.Open(configPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open config file %s: %w", configPath, err)
-	}
-	defer file.Close()
-
-	var profiles []string
-	re := regexp.MustCompile(`^\[profile\s+(.+?)\]$`)
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		matches := re.FindStringSubmatch(line)
-		if len(matches) > 1 {
-			profiles = append(profiles, matches[1])
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error while reading file: %w", err)
-	}
-	return profiles, nil
-}
-
-// getRegionForProfile gets the region for a given AWS profile.
-func getRegionForProfile(profile string) (string, error) {
-	region := getPropertyForProfile(profile, "region")
+// getRegionForProfile gets the region for a given AWS profile, offering the
+// default region of the settings when it has none.
+func getRegionForProfile(profile string, settings Settings) (string, error) {
+	region := profileRegion(profile)
 	if region != "" {
 		return region, nil
 	}
 
 	fmt.Println("Info: Region is not configured for this profile.")
 
-	prompt := promptui.Prompt{Label: "Enter AWS Region", Default: "ap-southeast-1"}
+	prompt := promptui.Prompt{Label: "Enter AWS Region", Default: settings.DefaultRegion}
 	result, err := prompt.Run()
 	if err != nil {
 		return "", fmt.Errorf("region selection cancelled: %w", err)
@@ -57,118 +34,278 @@ func getRegionForProfile(profile string) (string, error) {
 	return result, nil
 }
 
-// Helper to run 'aws configure get'
-func getPropertyForProfile(profile, key string) string {
-	cmd := exec.Command("aws", "configure", "get", key, "--profile", profile)
-	output, err := cmd.Output()
+// isSsoProfile reports whether a profile gets its credentials from IAM Identity Center.
+func isSsoProfile(profile string) bool {
+	cfg, err := loadProfileConfig(profile)
 	if err != nil {
-		return "" // Key or profile does not exist
+		return false
 	}
-	return strings.TrimSpace(string(output))
+	return cfg.usesSSO()
 }
 
-func isSsoProfile(profile string) bool {
-	ssoAccountId := getPropertyForProfile(profile, "sso_account_id")
-
-	return ssoAccountId != ""
+// getAndSelectInstance fetches a list of EC2 instances and prompts the user to select one.
+func getAndSelectInstance(profile, region string, settings Settings) (*EC2Instance, error) {
+	instances, err := fetchRunningInstances(profile, region)
+	if err != nil {
+		return nil, err
+	}
+	usageData, err := loadInstanceUsageData()
+	if err != nil {
+		return nil, err
+	}
+	for i := range instances {
+		instances[i].Usage = usageData[instances[i].ID]
+	}
+	sortByFrecency(instances, func(inst EC2Instance) usageRecord { return inst.Usage })
+	return selectInstanceFromList(instances, settings)
 }
 
-// getAndSelectInstance fetches a list of EC2 instances and prompts the user to select one.
+// fetchRunningInstances returns all running EC2 instances for the given profile and region.
 // It also handles expired SSO tokens.
-func getAndSelectInstance(profile, region string, retryCount int) (*EC2Instance, error) {
-	fmt.Println(Cyan("ℹ️  Fetching running EC2 instances..."))
-	cmd := exec.Command("aws", "ec2", "describe-instances", "--profile", profile, "--region", region, "--filters", "Name=instance-state-name,Values=running", "--query", "Reservations[].Instances[].{ID:InstanceId,Name:Tags[?Key=='Name']|[0].Value}", "--output", "json")
-	output, err := cmd.CombinedOutput()
+func fetchRunningInstances(profile, region string) ([]EC2Instance, error) {
+	instances, err := queryRunningInstances(profile, region, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("no running EC2 instances found in region %s", region)
+	}
+	return instances, nil
+}
 
-	if err == nil {
-		var instances []EC2Instance
-		if err := json.Unmarshal(output, &instances); err != nil {
-			return nil, fmt.Errorf("failed to parse JSON output: %w", err)
+// queryRunningInstances returns the running EC2 instances matching the given
+// additional filters.
+func queryRunningInstances(profile, region string, filters []ec2types.Filter) ([]EC2Instance, error) {
+	fmt.Fprintln(os.Stderr, Cyan("ℹ️  Fetching running EC2 instances..."))
+	input := &ec2.DescribeInstancesInput{
+		Filters: append([]ec2types.Filter{{Name: aws.String("instance-state-name"), Values: []string{"running"}}}, filters...),
+	}
+
+	var instances []EC2Instance
+	err := callAWS(profile, region, func(ctx context.Context, cfg aws.Config) error {
+		instances = nil
+		paginator := ec2.NewDescribeInstancesPaginator(ec2.NewFromConfig(cfg), input)
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return err
+			}
+			for _, reservation := range page.Reservations {
+				for _, inst := range reservation.Instances {
+					instances = append(instances, newEC2Instance(inst))
+				}
+			}
 		}
-		if len(instances) == 0 {
-			return nil, fmt.Errorf("no running EC2 instances found in region %s", region)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch running EC2 instances: %w", err)
+	}
+	return instances, nil
+}
+
+// newEC2Instance converts an instance returned by the EC2 API.
+func newEC2Instance(inst ec2types.Instance) EC2Instance {
+	result := EC2Instance{ID: aws.ToString(inst.InstanceId), LaunchTime: aws.ToTime(inst.LaunchTime), Tags: make(map[string]string)}
+	for _, tag := range inst.Tags {
+		result.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		switch aws.ToString(tag.Key) {
+		case "Name":
+			result.Name = tag.Value
+		case "aws:autoscaling:groupName":
+			result.ASG = tag.Value
 		}
-		usageData, err := loadInstanceUsageData()
-		if err != nil {
-			return nil, err
+	}
+	if inst.Platform != "" {
+		platform := string(inst.Platform)
+		result.Platform = &platform
+	}
+	return result
+}
+
+// sessionCommand describes the 'aws ssm start-session' call for a shortcut.
+type sessionCommand struct {
+	Args      []string
+	LocalPort int    // Local port of a port forwarding session, zero otherwise.
+	Remote    string // "host:port" reached by a port forwarding session.
+}
+
+// executeFinalAction executes the final command or displays it if in dry run mode.
+// Before a session starts, an SSO token expiring within SSORefreshWindow is refreshed.
+// With Background set, port forwarding sessions are handed to the tunnel supervisor;
+// with Reconnect set, they are restarted whenever they drop.
+func (a *App) executeFinalAction(sc *Shortcut, region string) error {
+	if sc.Action == actionRunCommand {
+		return a.runCommandAction(sc, region)
+	}
+
+	sessions, err := a.buildSessionCommands(sc, region)
+	if err != nil {
+		return err
+	}
+
+	if a.DryRun {
+		fmt.Println(Cyan("\n-- DRY RUN MODE --"))
+		if len(sessions) == 1 {
+			fmt.Println("Command to be executed:")
+		} else {
+			fmt.Println("Commands to be executed:")
 		}
-		for i := range instances {
-			instances[i].UsageCount = usageData[instances[i].ID]
+		for _, session := range sessions {
+			fmt.Println(Yellow("aws " + strings.Join(session.Args, " ")))
 		}
-		sort.Slice(instances, func(i, j int) bool {
-			return instances[i].UsageCount > instances[j].UsageCount
-		})
-		return selectInstanceFromList(instances)
+		return nil
 	}
 
-	if retryCount > 0 {
-		return nil, fmt.Errorf("failed to refresh SSO token after retry")
+	if err := ensureSSOToken(sc.Profile, a.settings.SSORefreshWindow); err != nil {
+		return err
 	}
 
-	if canRetry, err := executeRefreshProfileAction(profile); err != nil {
-		return nil, err
-	} else if canRetry {
-		fmt.Println(Cyan("🔁 Retrying..."))
-		return getAndSelectInstance(profile, region, retryCount+1)
+	if a.Background {
+		for i := range sessions {
+			if err := a.startBackgroundTunnel(sc, &sessions[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(sessions) > 1 {
+		argsList := make([][]string, len(sessions))
+		for i, session := range sessions {
+			argsList[i] = session.Args
+		}
+		return runSupervisedSessions(sc.Profile, argsList, a.Reconnect)
+	}
+	session := sessions[0]
+	if a.Reconnect && session.LocalPort != 0 {
+		return runSupervisedSessions(sc.Profile, [][]string{session.Args}, true)
 	}
+	return executeInteractiveAWSCommand(sc.Profile, session.Args, 0)
+}
 
-	return nil, fmt.Errorf("failed to fetch running EC2 instances: %s", string(output))
+// buildSessionCommands prepares the sessions to start for a shortcut's action.
+// Every action but instance port forwarding uses a single session.
+func (a *App) buildSessionCommands(sc *Shortcut, region string) ([]sessionCommand, error) {
+	if sc.Action == actionInstancePort {
+		return a.buildInstancePortSessions(sc, region)
+	}
+	session, err := a.buildSessionCommand(sc, region)
+	if err != nil {
+		return nil, err
+	}
+	return []sessionCommand{*session}, nil
 }
 
-// executeFinalAction executes the final command or displays it if in dry run mode.
-func executeFinalAction(sc *Shortcut, region string, dryRun bool) error {
-	var args []string
-	if sc.Action == "Start Session (SSM)" {
+// buildSessionCommand prepares the AWS CLI arguments for a shortcut's action.
+func (a *App) buildSessionCommand(sc *Shortcut, region string) (*sessionCommand, error) {
+	switch sc.Action {
+	case actionSSM:
+		if a.Background {
+			return nil, newExitError(ExitUsage, "'%s' is interactive and cannot run in the background", actionSSM)
+		}
 		fmt.Println(Cyan("Preparing SSM session..."))
-		args = []string{"ssm", "start-session", "--target", sc.InstanceID, "--profile", sc.Profile, "--region", region}
-	} else if sc.Action == "Connect RDS" {
+		return &sessionCommand{Args: []string{"ssm", "start-session", "--target", sc.InstanceID, "--profile", sc.Profile, "--region", region}}, nil
+	case actionRDS:
 		fmt.Println(Cyan("Preparing port forwarding to RDS..."))
-		allConfigs, _ := loadRDSConfig()
+		allConfigs, _ := loadRDSTargets(sc.Profile, region, a.settings)
 		var targetRDS RDSConfig
 		for _, conf := range allConfigs {
-			confID := fmt.Sprintf("%s|%s|%s", conf.Key, conf.Env, conf.Type)
-			if confID == sc.RDS_ID {
+			if conf.ID() == sc.RDS_ID {
 				targetRDS = conf
 				break
 			}
 		}
 		if targetRDS.Endpoint == "" {
-			return fmt.Errorf("RDS configuration for shortcut not found")
+			return nil, fmt.Errorf("RDS configuration for shortcut not found")
+		}
+		localPort, err := allocateLocalPort(targetRDS.LocalPort, a.settings.PortConflict)
+		if err != nil {
+			return nil, err
 		}
-		parameters := fmt.Sprintf("host=%s,portNumber=%d,localPortNumber=%d", targetRDS.Endpoint, targetRDS.Port, targetRDS.LocalPort)
-		args = []string{"ssm", "start-session", "--target", sc.InstanceID, "--profile", sc.Profile, "--region", region, "--document-name", "AWS-StartPortForwardingSessionToRemoteHost", "--parameters", parameters}
-		fmt.Printf(Cyan("Target: %s -> localhost:%d\n"), targetRDS.Endpoint, targetRDS.LocalPort)
+		parameters := fmt.Sprintf("host=%s,portNumber=%d,localPortNumber=%d", targetRDS.Endpoint, targetRDS.Port, localPort)
+		args := []string{"ssm", "start-session", "--target", sc.InstanceID, "--profile", sc.Profile, "--region", region, "--document-name", "AWS-StartPortForwardingSessionToRemoteHost", "--parameters", parameters}
+		fmt.Printf(Cyan("Target: %s -> localhost:%d\n"), targetRDS.Endpoint, localPort)
+		return &sessionCommand{Args: args, LocalPort: localPort, Remote: fmt.Sprintf("%s:%d", targetRDS.Endpoint, targetRDS.Port)}, nil
+	case actionForward:
+		fmt.Println(Cyan("Preparing port forwarding to remote host..."))
+		allTargets, err := loadTargets(sc.Profile, region, a.settings)
+		if err != nil {
+			return nil, err
+		}
+		target, ok := findTarget(allTargets, sc.TargetID)
+		if !ok {
+			return nil, fmt.Errorf("target '%s' for shortcut not found", sc.TargetID)
+		}
+		localPort, err := allocateLocalPort(target.LocalPort, a.settings.PortConflict)
+		if err != nil {
+			return nil, err
+		}
+		parameters := fmt.Sprintf("host=%s,portNumber=%d,localPortNumber=%d", target.Endpoint, target.Port, localPort)
+		args := []string{"ssm", "start-session", "--target", sc.InstanceID, "--profile", sc.Profile, "--region", region, "--document-name", "AWS-StartPortForwardingSessionToRemoteHost", "--parameters", parameters}
+		fmt.Printf(Cyan("Target: %s %s -> localhost:%d\n"), target.Kind, target.Endpoint, localPort)
+		return &sessionCommand{Args: args, LocalPort: localPort, Remote: fmt.Sprintf("%s:%d", target.Endpoint, target.Port)}, nil
+	default:
+		return nil, fmt.Errorf("unknown action '%s'", sc.Action)
 	}
+}
 
-	if dryRun {
-		fullCommand := "aws " + strings.Join(args, " ")
-		fmt.Println(Cyan("\n-- DRY RUN MODE --"))
-		fmt.Println("Command to be executed:")
-		fmt.Println(Yellow(fullCommand))
-		return nil
+// newAWSCommand prepares an AWS CLI command for a profile. Assume-role
+// profiles run with their cached temporary credentials in the environment and
+// without --profile, which would make the CLI assume the role, and ask for the
+// MFA code, on its own.
+func newAWSCommand(profile string, args []string) (*exec.Cmd, error) {
+	if !isAssumeRoleProfile(profile) {
+		return exec.Command("aws", args...), nil
 	}
-
-	return executeInteractiveAWSCommand(sc.Profile, args, 0)
+	env, err := roleCredentialsEnv(profile)
+	if err != nil {
+		return nil, err
+	}
+	var cliArgs []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--profile" {
+			i++
+			continue
+		}
+		cliArgs = append(cliArgs, args[i])
+	}
+	cmd := exec.Command("aws", cliArgs...)
+	cmd.Env = env
+	return cmd, nil
 }
 
 // executeInteractiveAWSCommand runs an AWS command that requires user interaction.
+// Only a failure caused by an expired token refreshes the SSO token and
+// retries; other failures are explained, and a session that ends with a
+// non-zero status without an error from AWS is not a failure.
 func executeInteractiveAWSCommand(profile string, args []string, retryCount int) error {
-	cmd := exec.Command("aws", args...)
+	cmd, err := newAWSCommand(profile, args)
+	if err != nil {
+		return err
+	}
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 
 	var stderrBuf bytes.Buffer
 	cmd.Stderr = io.MultiWriter(os.Stderr, &stderrBuf)
 
-	err := cmd.Run()
-	stderrString := stderrBuf.String()
+	err = cmd.Run()
+	stderrString := strings.TrimSpace(stderrBuf.String())
 
 	if err == nil {
 		return nil
 	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return fmt.Errorf("failed to run AWS CLI: %w", err)
+	}
 
-	if retryCount > 0 {
-		return fmt.Errorf("failed to refresh SSO token after retry")
+	failure := classifyAWSOutput(stderrString)
+	if failure == failureUnknown && stderrString == "" {
+		return nil
+	}
+	if failure != failureExpiredToken || retryCount > 0 {
+		return withExitCode(ExitAWS, newAWSCLIError(profile, stderrString, err))
 	}
 
 	if canRetry, err := executeRefreshProfileAction(profile); err != nil {
@@ -178,15 +315,23 @@ func executeInteractiveAWSCommand(profile string, args []string, retryCount int)
 		return executeInteractiveAWSCommand(profile, args, retryCount+1)
 	}
 
-	return fmt.Errorf("failed to run AWS CLI command: %s", stderrString)
+	return withExitCode(ExitAWS, newAWSCLIError(profile, stderrString, err))
 }
 
 func executeRefreshProfileAction(profile string) (bool, error) {
+	if isAssumeRoleProfile(profile) {
+		fmt.Fprintln(os.Stderr, Yellow("⚠️  Temporary credentials have expired. Assuming the role again..."))
+		if err := forgetRoleCredentials(profile); err != nil {
+			return false, err
+		}
+		forgetAWSConfigs(profile)
+		return true, nil
+	}
 	if !isSsoProfile(profile) {
 		return false, nil
 	}
 
-	fmt.Println(Yellow("⚠️  SSO token has expired. Starting to refresh the token..."))
+	fmt.Fprintln(os.Stderr, Yellow("⚠️  SSO token has expired. Starting to refresh the token..."))
 
 	ssoRefreshed, err := ssoRefresh(profile)
 	if err != nil {
@@ -0,0 +1,133 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// awsCallTimeout bounds a single AWS API call, including its retries.
+const awsCallTimeout = time.Minute
+
+// awsConfigs caches the SDK configuration of each profile and region, so
+// credentials are resolved once per run.
+var (
+	awsConfigsMu sync.Mutex
+	awsConfigs   = make(map[string]aws.Config)
+)
+
+// loadAWSConfig returns the SDK configuration for a profile and region.
+func loadAWSConfig(profile, region string) (aws.Config, error) {
+	key := profile + "|" + region
+	awsConfigsMu.Lock()
+	defer awsConfigsMu.Unlock()
+	if cfg, ok := awsConfigs[key]; ok {
+		return cfg, nil
+	}
+
+	opts := []func(*config.LoadOptions) error{config.WithSharedConfigProfile(profile), config.WithRegion(region)}
+	if isAssumeRoleProfile(profile) {
+		// Assume-role profiles share asmago's credentials cache and MFA prompt.
+		opts = append(opts, config.WithCredentialsProvider(aws.NewCredentialsCache(roleCredentialsProvider{profile: profile})))
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load AWS configuration for profile '%s': %w", profile, err)
+	}
+	awsConfigs[key] = cfg
+	return cfg, nil
+}
+
+// forgetAWSConfigs drops the cached configurations of a profile, so that
+// credentials are resolved again after an SSO login.
+func forgetAWSConfigs(profile string) {
+	awsConfigsMu.Lock()
+	defer awsConfigsMu.Unlock()
+	for key := range awsConfigs {
+		if strings.HasPrefix(key, profile+"|") {
+			delete(awsConfigs, key)
+		}
+	}
+}
+
+// loadProfileConfig returns the settings of a profile from the shared AWS
+// config and credentials files.
+func loadProfileConfig(profile string) (*awsProfile, error) {
+	c, err := loadAWSSharedConfig()
+	if err != nil {
+		return nil, err
+	}
+	return c.profile(profile)
+}
+
+// profileRegion returns the region set by AWS_REGION or AWS_DEFAULT_REGION,
+// or else the region preferred for a profile in config.yaml, or the region
+// configured for it in ~/.aws/config, or an empty string.
+func profileRegion(profile string) string {
+	if region := envRegion(); region != "" {
+		return region
+	}
+	if region := loadSettings().profile(profile).Region; region != "" {
+		return region
+	}
+	cfg, err := loadProfileConfig(profile)
+	if err != nil {
+		return ""
+	}
+	return cfg.Region
+}
+
+// callAWS runs an AWS API call with the configuration of a profile and
+// region. If it fails because the SSO token expired, the token is refreshed
+// and the call is retried once. The first call of a profile refreshes a
+// token the SSO cache already shows as expired. Other recognized failures
+// are returned as an awsError explaining them.
+func callAWS(profile, region string, call func(ctx context.Context, cfg aws.Config) error) error {
+	if err := ensureSSOTokenOnce(profile); err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		cfg, err := loadAWSConfig(profile, region)
+		if err != nil {
+			return err
+		}
+		started := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), awsCallTimeout)
+		err = call(ctx, cfg)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		failure := classifyAWSError(err)
+		if failure == failureUnknown {
+			return err
+		}
+		if failure != failureExpiredToken || attempt > 0 {
+			return &awsError{Failure: failure, Profile: profile, Err: err}
+		}
+
+		if canRetry, refreshErr := refresherFor(profile).refresh(started); refreshErr != nil {
+			return refreshErr
+		} else if !canRetry {
+			return &awsError{Failure: failure, Profile: profile, Err: err}
+		}
+		forgetAWSConfigs(profile)
+		fmt.Fprintln(os.Stderr, Cyan("🔁 Retrying..."))
+	}
+}
+
+// profileRefreshers holds an ssoRefresher per profile, so concurrent calls
+// failing on the same expired token log in only once.
+var profileRefreshers sync.Map
+
+// refresherFor returns the ssoRefresher of a profile.
+func refresherFor(profile string) *ssoRefresher {
+	r, _ := profileRefreshers.LoadOrStore(profile, &ssoRefresher{profile: profile})
+	return r.(*ssoRefresher)
+}
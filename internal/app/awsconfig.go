@@ -0,0 +1,265 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// awsProfile holds the settings of a profile, merged from the shared config
+// and credentials files. Values from the credentials file take precedence.
+type awsProfile struct {
+	Name       string
+	Properties map[string]string // All keys, lowercased; nested keys as "parent.key".
+
+	Region        string
+	SSOSession    string // Name of the sso-session section used by the profile.
+	SSOStartURL   string // From the profile or its sso-session.
+	SSORegion     string // From the profile or its sso-session.
+	SSOAccountID  string
+	SSORoleName   string
+	RoleARN       string
+	SourceProfile string
+	MFASerial     string
+}
+
+// usesSSO reports whether the profile gets its credentials from IAM Identity Center.
+func (p *awsProfile) usesSSO() bool {
+	return p.SSOSession != "" || (p.SSOStartURL != "" && p.SSOAccountID != "")
+}
+
+// awsSharedConfig is the content of the shared config and credentials files.
+type awsSharedConfig struct {
+	profiles    map[string]*awsProfile
+	names       []string                     // Profile names in the order they appear.
+	ssoSessions map[string]map[string]string // Properties of each sso-session section.
+}
+
+// iniSection is a section of an INI file with its properties.
+type iniSection struct {
+	Name       string
+	Properties map[string]string
+}
+
+// parseINI parses an AWS shared config or credentials file. Lines starting
+// with '#' or ';' are comments, as is the rest of a value after " #" or " ;".
+// Indented lines below a key without a value are nested properties, stored
+// as "parent.key"; other indented lines continue the previous value.
+func parseINI(r io.Reader, path string) ([]iniSection, error) {
+	var sections []iniSection
+	var current *iniSection
+	var lastKey string
+	nested := false
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("%s:%d: missing ']' in section header", path, lineNo)
+			}
+			sections = append(sections, iniSection{Name: strings.Join(strings.Fields(line[1:end]), " "), Properties: make(map[string]string)})
+			current = &sections[len(sections)-1]
+			lastKey = ""
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("%s:%d: property outside of a section", path, lineNo)
+		}
+
+		indented := raw[0] == ' ' || raw[0] == '\t'
+		key, value, found := strings.Cut(line, "=")
+		switch {
+		case indented && lastKey != "" && nested && found:
+			current.Properties[lastKey+"."+strings.ToLower(strings.TrimSpace(key))] = stripINIComment(value)
+		case indented && lastKey != "" && !nested:
+			current.Properties[lastKey] += "\n" + stripINIComment(line)
+		case found:
+			lastKey = strings.ToLower(strings.TrimSpace(key))
+			current.Properties[lastKey] = stripINIComment(value)
+			nested = current.Properties[lastKey] == ""
+		default:
+			return nil, fmt.Errorf("%s:%d: expected 'key = value'", path, lineNo)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return sections, nil
+}
+
+// stripINIComment trims a value and removes an inline comment.
+func stripINIComment(value string) string {
+	for _, marker := range []string{" #", "\t#", " ;", "\t;"} {
+		if i := strings.Index(value, marker); i >= 0 {
+			value = value[:i]
+		}
+	}
+	return strings.TrimSpace(value)
+}
+
+// awsConfigFilePath returns the path of the shared config file, honoring
+// AWS_CONFIG_FILE.
+func awsConfigFilePath() (string, error) {
+	return awsFilePath("AWS_CONFIG_FILE", "config")
+}
+
+// awsCredentialsFilePath returns the path of the shared credentials file,
+// honoring AWS_SHARED_CREDENTIALS_FILE.
+func awsCredentialsFilePath() (string, error) {
+	return awsFilePath("AWS_SHARED_CREDENTIALS_FILE", "credentials")
+}
+
+// awsFilePath returns the path in the named environment variable, with a
+// leading ~ expanded, or ~/.aws/<name>.
+func awsFilePath(envName, name string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	path := os.Getenv(envName)
+	if path == "" {
+		return filepath.Join(homeDir, ".aws", name), nil
+	}
+	if rest, ok := strings.CutPrefix(path, "~"); ok {
+		return filepath.Join(homeDir, rest), nil
+	}
+	return path, nil
+}
+
+// readINIFile parses an INI file. A missing file has no sections.
+func readINIFile(path string) ([]iniSection, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+	return parseINI(file, path)
+}
+
+var (
+	sharedConfigOnce sync.Once
+	sharedConfig     *awsSharedConfig
+	sharedConfigErr  error
+)
+
+// loadAWSSharedConfig reads the shared config and credentials files once per run.
+func loadAWSSharedConfig() (*awsSharedConfig, error) {
+	sharedConfigOnce.Do(func() {
+		sharedConfig, sharedConfigErr = readAWSSharedConfig()
+	})
+	return sharedConfig, sharedConfigErr
+}
+
+// readAWSSharedConfig reads and merges the shared config and credentials files.
+func readAWSSharedConfig() (*awsSharedConfig, error) {
+	configPath, err := awsConfigFilePath()
+	if err != nil {
+		return nil, err
+	}
+	credentialsPath, err := awsCredentialsFilePath()
+	if err != nil {
+		return nil, err
+	}
+	configSections, err := readINIFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	credentialsSections, err := readINIFile(credentialsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &awsSharedConfig{profiles: make(map[string]*awsProfile), ssoSessions: make(map[string]map[string]string)}
+	addProperties := func(name string, properties map[string]string) {
+		p, ok := c.profiles[name]
+		if !ok {
+			p = &awsProfile{Name: name, Properties: make(map[string]string)}
+			c.profiles[name] = p
+			c.names = append(c.names, name)
+		}
+		for k, v := range properties {
+			p.Properties[k] = v
+		}
+	}
+
+	// In the config file, profiles other than default are named
+	// "profile <name>"; sections without a known prefix are ignored.
+	for _, s := range configSections {
+		switch {
+		case s.Name == "default":
+			addProperties("default", s.Properties)
+		case strings.HasPrefix(s.Name, "profile "):
+			addProperties(strings.TrimPrefix(s.Name, "profile "), s.Properties)
+		case strings.HasPrefix(s.Name, "sso-session "):
+			c.ssoSessions[strings.TrimPrefix(s.Name, "sso-session ")] = s.Properties
+		}
+	}
+	for _, s := range credentialsSections {
+		addProperties(s.Name, s.Properties)
+	}
+
+	for _, p := range c.profiles {
+		props := p.Properties
+		p.Region = props["region"]
+		p.SSOSession = props["sso_session"]
+		p.SSOStartURL = props["sso_start_url"]
+		p.SSORegion = props["sso_region"]
+		p.SSOAccountID = props["sso_account_id"]
+		p.SSORoleName = props["sso_role_name"]
+		p.RoleARN = props["role_arn"]
+		p.SourceProfile = props["source_profile"]
+		p.MFASerial = props["mfa_serial"]
+		if session, ok := c.ssoSessions[p.SSOSession]; ok {
+			p.SSOStartURL = session["sso_start_url"]
+			p.SSORegion = session["sso_region"]
+		}
+	}
+	return c, nil
+}
+
+// profile returns the settings of a profile, or an ExitNotFound error.
+func (c *awsSharedConfig) profile(name string) (*awsProfile, error) {
+	p, ok := c.profiles[name]
+	if !ok {
+		return nil, newExitError(ExitNotFound, "AWS profile '%s' not found", name)
+	}
+	return p, nil
+}
+
+// ssoSessionExists reports whether the config file has an sso-session section with that name.
+func (c *awsSharedConfig) ssoSessionExists(name string) bool {
+	_, ok := c.ssoSessions[name]
+	return ok
+}
+
+// defaultProfile returns the profile selected by AWS_PROFILE, or
+// AWS_DEFAULT_PROFILE, or an empty string.
+func defaultProfile() string {
+	if profile := os.Getenv("AWS_PROFILE"); profile != "" {
+		return profile
+	}
+	return os.Getenv("AWS_DEFAULT_PROFILE")
+}
+
+// envRegion returns the region set by AWS_REGION or AWS_DEFAULT_REGION, which
+// take precedence over the region of a profile.
+func envRegion() string {
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
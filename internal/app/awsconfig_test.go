@@ -0,0 +1,188 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseINI(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []iniSection
+	}{
+		{
+			name:  "default section and comments",
+			input: "# comment\n; comment\n[default]\nregion = us-east-1\n\noutput=json\n",
+			want:  []iniSection{{Name: "default", Properties: map[string]string{"region": "us-east-1", "output": "json"}}},
+		},
+		{
+			name:  "section names are normalized",
+			input: "[ profile   dev ]\nRegion = eu-west-1\n",
+			want:  []iniSection{{Name: "profile dev", Properties: map[string]string{"region": "eu-west-1"}}},
+		},
+		{
+			name:  "inline comments need white space before them",
+			input: "[profile dev]\nsso_start_url = https://my.awsapps.com/start#/\nregion = ap-southeast-1 # Singapore\nrole_arn = arn:aws:iam::1:role/a;b\noutput = json\t; tab comment\n",
+			want: []iniSection{{Name: "profile dev", Properties: map[string]string{
+				"sso_start_url": "https://my.awsapps.com/start#/",
+				"region":        "ap-southeast-1",
+				"role_arn":      "arn:aws:iam::1:role/a;b",
+				"output":        "json",
+			}}},
+		},
+		{
+			name:  "nested s3 block",
+			input: "[profile dev]\nregion = us-east-1\ns3 =\n  max_concurrent_requests = 20 # more\n  addressing_style = path\ncli_pager =\n",
+			want: []iniSection{{Name: "profile dev", Properties: map[string]string{
+				"region":                     "us-east-1",
+				"s3":                         "",
+				"s3.max_concurrent_requests": "20",
+				"s3.addressing_style":        "path",
+				"cli_pager":                  "",
+			}}},
+		},
+		{
+			name:  "continuation lines",
+			input: "[profile dev]\ncredential_process = /usr/bin/helper\n  --profile dev\n\t--verbose\nregion = us-east-1\n",
+			want: []iniSection{{Name: "profile dev", Properties: map[string]string{
+				"credential_process": "/usr/bin/helper\n--profile dev\n--verbose",
+				"region":             "us-east-1",
+			}}},
+		},
+		{
+			name:  "several sections",
+			input: "[default]\nregion = us-east-1\n[sso-session corp]\nsso_region = eu-west-1\n",
+			want: []iniSection{
+				{Name: "default", Properties: map[string]string{"region": "us-east-1"}},
+				{Name: "sso-session corp", Properties: map[string]string{"sso_region": "eu-west-1"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseINI(strings.NewReader(tt.input), "config")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v\nwant %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseINIErrors(t *testing.T) {
+	tests := []struct{ name, input, want string }{
+		{"unclosed section", "[profile dev\nregion = us-east-1\n", "config:1: missing ']'"},
+		{"property before any section", "region = us-east-1\n", "config:1: property outside of a section"},
+		{"line without equals sign", "[default]\nregion = us-east-1\nnonsense\n", "config:3: expected 'key = value'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseINI(strings.NewReader(tt.input), "config")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadAWSSharedConfig(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config")
+	credentialsPath := filepath.Join(dir, "credentials")
+	writeFile := func(path, content string) {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(configPath, `[default]
+region = us-east-1
+
+[profile dev]
+sso_session = corp
+sso_account_id = 111111111111
+sso_role_name = Dev
+region = ap-southeast-1
+
+[profile legacy]
+sso_start_url = https://legacy.awsapps.com/start
+sso_region = us-west-2
+sso_account_id = 222222222222
+sso_role_name = Admin
+
+[profile admin]
+role_arn = arn:aws:iam::333333333333:role/Admin
+source_profile = static
+mfa_serial = arn:aws:iam::111111111111:mfa/me
+
+[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start#/
+sso_region = eu-west-1
+
+[static]
+region = ignored-without-profile-prefix
+`)
+	writeFile(credentialsPath, `[default]
+aws_access_key_id = AKIADEFAULT
+aws_secret_access_key = secret
+
+[static]
+aws_access_key_id = AKIASTATIC
+aws_secret_access_key = secret
+`)
+	t.Setenv("AWS_CONFIG_FILE", configPath)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsPath)
+
+	c, err := readAWSSharedConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"default", "dev", "legacy", "admin", "static"}; !reflect.DeepEqual(c.names, want) {
+		t.Errorf("got profiles %v, want %v", c.names, want)
+	}
+
+	tests := []struct {
+		profile string
+		check   func(p *awsProfile) bool
+		what    string
+	}{
+		{"default", func(p *awsProfile) bool {
+			return p.Region == "us-east-1" && p.Properties["aws_access_key_id"] == "AKIADEFAULT"
+		}, "region from config and keys from credentials"},
+		{"static", func(p *awsProfile) bool {
+			return p.Region == "" && p.Properties["aws_access_key_id"] == "AKIASTATIC" && !p.usesSSO()
+		}, "a credentials-only profile without region"},
+		{"dev", func(p *awsProfile) bool {
+			return p.usesSSO() && p.SSOSession == "corp" && p.SSOStartURL == "https://corp.awsapps.com/start#/" && p.SSORegion == "eu-west-1" && p.Region == "ap-southeast-1"
+		}, "start URL and region from its sso-session"},
+		{"legacy", func(p *awsProfile) bool {
+			return p.usesSSO() && p.SSOSession == "" && p.SSOStartURL == "https://legacy.awsapps.com/start" && p.SSORegion == "us-west-2"
+		}, "legacy SSO settings in the profile"},
+		{"admin", func(p *awsProfile) bool {
+			return p.RoleARN == "arn:aws:iam::333333333333:role/Admin" && p.SourceProfile == "static" && p.MFASerial == "arn:aws:iam::111111111111:mfa/me" && !p.usesSSO()
+		}, "assume-role settings"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			p, err := c.profile(tt.profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(p) {
+				t.Errorf("profile %s: want %s, got %+v", tt.profile, tt.what, p)
+			}
+		})
+	}
+
+	if !c.ssoSessionExists("corp") || c.ssoSessionExists("dev") {
+		t.Errorf("ssoSessionExists: want only 'corp' to exist")
+	}
+	if _, err := c.profile("missing"); ExitCode(err) != ExitNotFound {
+		t.Errorf("got %v for a missing profile, want an ExitNotFound error", err)
+	}
+}
@@ -0,0 +1,410 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configTemplate is written by 'asmago config edit' when config.yaml does
+// not exist yet. Every key is commented out and shows its default.
+const configTemplate = `# asmago configuration. Every key is optional; the values shown are the
+# defaults. ASMAGO_* environment variables override the values set here.
+
+# defaults:
+#   region: ap-southeast-1        # Offered when a profile has no region.
+#   replacement_policy: ask       # ask, newest or fail, when a shortcut's instance is gone.
+#   rds_discovery: true           # Discover RDS/Aurora endpoints through the AWS API.
+#   rds_discovery_ttl: 1h         # How long discovered endpoints are cached; 0 disables the cache.
+#   port_conflict: next           # next or fail, when a local port is in use.
+#   reconnect: false              # Restart port forwarding sessions that drop.
+#   sso_refresh_window: 10m       # Refresh SSO tokens expiring within this window.
+
+# ui:
+#   shortcut_limit: 5             # Shortcuts shown in the picker, besides pinned ones.
+#   page_size: 10                 # Items shown at once in pickers.
+
+# Rules mapping instances to the environment of their targets. The first
+# matching rule wins; match is name, profile or tag:<Key>, with a glob or a
+# regex. Without env_rules, names starting with dev or qa are detected.
+# env_rules:
+#   - match: tag:Environment
+#     regex: ^(.+)$
+#     env: $1
+#   - match: name
+#     glob: stg-*
+#     env: staging
+
+# data_dir: ~/.local/share/asmago  # Shortcuts, usage data and caches.
+
+# Preferences by AWS profile.
+# profiles:
+#   prod:
+#     region: eu-west-1             # Used instead of the region in ~/.aws/config.
+#     env: prod                     # Offer only the targets of this environment.
+#     rds_discovery: false          # Overrides defaults.rds_discovery.
+`
+
+// ConfigFilePath returns the path of config.yaml, or of the file named by
+// ASMAGO_CONFIG_FILE.
+func ConfigFilePath() (string, error) {
+	if path := os.Getenv("ASMAGO_CONFIG_FILE"); path != "" {
+		return path, nil
+	}
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "config.yaml"), nil
+}
+
+// readConfigDocument parses config.yaml into a YAML document, keeping its
+// comments. A missing or empty file gives a document with an empty mapping.
+func readConfigDocument(path string) (*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if root := doc.Content[0]; root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s:%d: expected a mapping of settings at the top level", path, root.Line)
+	}
+	return &doc, nil
+}
+
+// applyConfigFile reads config.yaml into s. A missing file is not an error;
+// the returned problems name the faulty keys, which are left unchanged.
+func applyConfigFile(s *Settings, path string) []error {
+	doc, err := readConfigDocument(path)
+	if err != nil {
+		return []error{err}
+	}
+
+	var problems []error
+	problem := func(node *yaml.Node, key string, err error) {
+		problems = append(problems, fmt.Errorf("%s:%d: %s: %w", path, node.Line, key, err))
+	}
+
+	var walk func(node *yaml.Node, prefix string)
+	walk = func(node *yaml.Node, prefix string) {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			key := keyNode.Value
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+
+			switch {
+			case key == "env_rules":
+				s.EnvRules = decodeEnvRules(valueNode, problem)
+			case key == "profiles":
+				if s.Profiles == nil {
+					s.Profiles = make(map[string]profileSettings)
+				}
+				decodeProfiles(s.Profiles, valueNode, problem)
+			case valueNode.Kind == yaml.MappingNode:
+				walk(valueNode, key)
+			default:
+				k, ok := findSettingKey(key)
+				if !ok {
+					problem(keyNode, key, fmt.Errorf("unknown key"))
+					continue
+				}
+				if valueNode.Kind != yaml.ScalarNode {
+					problem(valueNode, key, fmt.Errorf("expected a single value"))
+					continue
+				}
+				if err := k.set(s, valueNode.Value); err != nil {
+					problem(valueNode, key, err)
+				}
+			}
+		}
+	}
+	walk(doc.Content[0], "")
+	return problems
+}
+
+// decodeEnvRules decodes the env_rules list. Invalid rules are reported and
+// skipped; an empty list disables environment detection.
+func decodeEnvRules(node *yaml.Node, problem func(node *yaml.Node, key string, err error)) []envRule {
+	rules := []envRule{}
+	if node.Kind != yaml.SequenceNode {
+		problem(node, "env_rules", fmt.Errorf("expected a list of rules"))
+		return nil
+	}
+	for i, ruleNode := range node.Content {
+		var rule envRule
+		if err := ruleNode.Decode(&rule); err != nil {
+			problem(ruleNode, fmt.Sprintf("env_rules[%d]", i+1), err)
+			continue
+		}
+		if err := rule.compile(); err != nil {
+			problem(ruleNode, fmt.Sprintf("env_rules[%d]", i+1), err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// decodeProfiles decodes the profiles mapping into profiles.
+func decodeProfiles(profiles map[string]profileSettings, node *yaml.Node, problem func(node *yaml.Node, key string, err error)) {
+	if node.Kind != yaml.MappingNode {
+		problem(node, "profiles", fmt.Errorf("expected a mapping of profile names"))
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name, settingsNode := node.Content[i].Value, node.Content[i+1]
+		if settingsNode.Kind != yaml.MappingNode {
+			problem(settingsNode, "profiles."+name, fmt.Errorf("expected a mapping of settings"))
+			continue
+		}
+		p := profiles[name]
+		for j := 0; j+1 < len(settingsNode.Content); j += 2 {
+			keyNode, valueNode := settingsNode.Content[j], settingsNode.Content[j+1]
+			if err := p.set(keyNode.Value, valueNode.Value); err != nil {
+				problem(keyNode, "profiles."+name+"."+keyNode.Value, err)
+			}
+		}
+		profiles[name] = p
+	}
+}
+
+// set parses value and stores it under key.
+func (p *profileSettings) set(key, value string) error {
+	switch key {
+	case "region":
+		p.Region = value
+	case "env":
+		p.Env = value
+	case "rds_discovery":
+		enabled, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		p.RDSDiscovery = &enabled
+	default:
+		return fmt.Errorf("unknown key (expected one of: %s)", strings.Join(profileSettingKeys, ", "))
+	}
+	return nil
+}
+
+// get returns the value stored under key, or an empty string.
+func (p profileSettings) get(key string) string {
+	switch key {
+	case "region":
+		return p.Region
+	case "env":
+		return p.Env
+	case "rds_discovery":
+		if p.RDSDiscovery != nil {
+			return strconv.FormatBool(*p.RDSDiscovery)
+		}
+	}
+	return ""
+}
+
+// splitProfileKey splits a "profiles.<name>.<key>" key.
+func splitProfileKey(key string) (name, field string, ok bool) {
+	rest, ok := strings.CutPrefix(key, "profiles.")
+	if !ok {
+		return "", "", false
+	}
+	i := strings.LastIndex(rest, ".")
+	if i <= 0 {
+		return "", "", false
+	}
+	return rest[:i], rest[i+1:], true
+}
+
+// ConfigGet prints the effective value of a setting, after config.yaml and
+// the environment are applied, or every setting when key is empty.
+func ConfigGet(key string) error {
+	s := loadSettings()
+	if key == "" {
+		for _, k := range settingKeys {
+			fmt.Printf("%s = %s\n", k.Key, k.get(s))
+		}
+		for i, rule := range s.envRules() {
+			fmt.Printf("env_rules[%d] = %s\n", i+1, rule)
+		}
+		for _, name := range slices.Sorted(maps.Keys(s.Profiles)) {
+			for _, field := range profileSettingKeys {
+				if value := s.Profiles[name].get(field); value != "" {
+					fmt.Printf("profiles.%s.%s = %s\n", name, field, value)
+				}
+			}
+		}
+		return nil
+	}
+
+	if name, field, ok := splitProfileKey(key); ok {
+		if !slices.Contains(profileSettingKeys, field) {
+			return newExitError(ExitUsage, "unknown key '%s' (profile keys: %s)", key, strings.Join(profileSettingKeys, ", "))
+		}
+		fmt.Println(s.profile(name).get(field))
+		return nil
+	}
+	k, ok := findSettingKey(key)
+	if !ok {
+		return newExitError(ExitUsage, "unknown key '%s'; run 'asmago config get' to list the keys", key)
+	}
+	fmt.Println(k.get(s))
+	return nil
+}
+
+// ConfigSet validates value and writes it under key in config.yaml, keeping
+// the rest of the file and its comments.
+func ConfigSet(key, value string) error {
+	var envName string
+	if name, field, ok := splitProfileKey(key); ok {
+		var p profileSettings
+		if err := p.set(field, value); err != nil {
+			return newExitError(ExitUsage, "invalid value for %s: %v", key, err)
+		}
+		value = p.get(field)
+		key = "profiles." + name + "." + field
+	} else if k, ok := findSettingKey(key); ok {
+		s := defaultSettings()
+		if err := k.set(&s, value); err != nil {
+			return newExitError(ExitUsage, "invalid value for %s: %v", key, err)
+		}
+		if k.Key != "data_dir" {
+			value = k.get(s)
+		}
+		envName = k.Env
+	} else if key == "env_rules" || strings.HasPrefix(key, "env_rules.") {
+		return newExitError(ExitUsage, "env_rules is a list; change it with 'asmago config edit'")
+	} else {
+		return newExitError(ExitUsage, "unknown key '%s'; run 'asmago config get' to list the keys", key)
+	}
+
+	path, err := ConfigFilePath()
+	if err != nil {
+		return err
+	}
+	doc, err := readConfigDocument(path)
+	if err != nil {
+		return fmt.Errorf("%w; fix it with 'asmago config edit'", err)
+	}
+	var fields []string
+	if name, field, ok := splitProfileKey(key); ok {
+		fields = []string{"profiles", name, field}
+	} else {
+		fields = strings.Split(key, ".")
+	}
+	if err := setYAMLValue(doc.Content[0], fields, value); err != nil {
+		return fmt.Errorf("cannot set %s in %s: %w", key, path, err)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := writeFileAtomic(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	fmt.Printf(Green("✅ Set %s to '%s' in %s\n"), key, value, path)
+	if envName != "" && os.Getenv(envName) != "" {
+		fmt.Printf(Yellow("Warning: %s is set and overrides this value\n"), envName)
+	}
+	return nil
+}
+
+// setYAMLValue sets the scalar at the path of keys below a mapping node,
+// creating the intermediate mappings.
+func setYAMLValue(node *yaml.Node, keys []string, value string) error {
+	for i, key := range keys {
+		var child *yaml.Node
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value == key {
+				child = node.Content[j+1]
+				break
+			}
+		}
+		last := i == len(keys)-1
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, child)
+		}
+		if last {
+			if child.Kind == yaml.MappingNode && len(child.Content) > 0 {
+				return fmt.Errorf("'%s' holds a mapping", strings.Join(keys, "."))
+			}
+			child.Kind, child.Tag, child.Value, child.Style, child.Content = yaml.ScalarNode, "", value, 0, nil
+			return nil
+		}
+		if child.Kind != yaml.MappingNode {
+			return fmt.Errorf("'%s' is not a mapping", strings.Join(keys[:i+1], "."))
+		}
+		node = child
+	}
+	return nil
+}
+
+// ConfigEdit opens config.yaml in $VISUAL or $EDITOR, creating it from a
+// documented template first, and reports problems in the edited file.
+func ConfigEdit() error {
+	path, err := ConfigFilePath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create config directory: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(configTemplate), 0644); err != nil {
+			return fmt.Errorf("failed to create %s: %w", path, err)
+		}
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+	args := append(strings.Fields(editor), path)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor '%s' failed: %w", editor, err)
+	}
+
+	s := defaultSettings()
+	problems := applyConfigFile(&s, path)
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, Yellow("Warning: %v\n"), problem)
+	}
+	if len(problems) > 0 {
+		return newExitError(ExitUsage, "%s has %d problem(s); the faulty values are ignored", path, len(problems))
+	}
+	fmt.Printf(Green("✅ %s is valid\n"), path)
+	return nil
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/fatih/color"
 )
@@ -20,6 +23,12 @@ var (
 // Constants
 const (
 	manualFlowChoice = "[ --- Run Manual Flow --- ]"
+
+	actionSSM          = "Start Session (SSM)"
+	actionRDS          = "Connect RDS"
+	actionForward      = "Forward to Remote Host"
+	actionInstancePort = "Forward Instance Port"
+	actionRunCommand   = "Run Command"
 )
 
 // --- EXPORTED PATH FUNCTIONS ---
@@ -35,42 +44,89 @@ func GetConfigDir() (string, error) {
 	return appConfigDir, nil
 }
 
-// GetDataDir returns the path to the application's data directory.
+// GetDataDir returns the path to the application's data directory, or the
+// data_dir setting when set.
 // Example: ~/.local/share/asmago/
 func GetDataDir() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("could not find user home directory: %w", err)
 	}
+	if dataDir := loadSettings().DataDir; dataDir != "" {
+		if rest, ok := strings.CutPrefix(dataDir, "~"); ok {
+			return filepath.Join(homeDir, rest), nil
+		}
+		return dataDir, nil
+	}
 	appDataDir := filepath.Join(homeDir, ".local", "share", "asmago")
 	return appDataDir, nil
 }
 
 // Data Structure Definitions
 type Shortcut struct {
-	Profile       string
-	InstanceID    string
-	InstanceName  string
-	Action        string
-	RDS_ID        string
-	DisplayString string
-	UsageCount    int
+	Name             string `json:",omitempty"`
+	Profile          string
+	InstanceID       string
+	InstanceName     string
+	AutoScalingGroup string `json:",omitempty"`
+	Action           string
+	RDS_ID           string
+	TargetID         string     `json:",omitempty"`
+	PortPairs        []PortPair `json:",omitempty"`
+	Command          string     `json:",omitempty"` // Shell command of the Run Command action.
+	DisplayString    string
+	UsageCount       int
+	Uses             []time.Time `json:",omitempty"` // Most recent uses, oldest first.
+	Pinned           bool        `json:",omitempty"`
+}
+
+// usage returns the usage record of the shortcut.
+func (sc Shortcut) usage() usageRecord {
+	return usageRecord{Count: sc.UsageCount, Uses: sc.Uses}
 }
 
 type EC2Instance struct {
 	ID         string
-	Name       *string `json:"Name"`
-	UsageCount int     `json:"-"`
+	Name       *string           `json:"Name"`
+	ASG        *string           `json:"ASG"`
+	Platform   *string           `json:"Platform"` // "windows" for Windows instances, empty otherwise.
+	LaunchTime time.Time         `json:"LaunchTime"`
+	Tags       map[string]string `json:"Tags,omitempty"`
+	Usage      usageRecord       `json:"-"`
+}
+
+// autoScalingGroup returns the name of the instance's Auto Scaling group, if any.
+func (i EC2Instance) autoScalingGroup() string {
+	if i.ASG == nil {
+		return ""
+	}
+	return *i.ASG
+}
+
+// displayName returns the instance's Name tag, or its ID when the tag is missing.
+func (i EC2Instance) displayName() string {
+	if i.Name == nil || *i.Name == "" {
+		return i.ID
+	}
+	return *i.Name
 }
 
 type RDSConfig struct {
-	Key        string `json:"key"`
-	Env        string `json:"env"`
-	Type       string `json:"type"`
-	Endpoint   string `json:"endpoint"`
-	Port       int    `json:"port"`
-	LocalPort  int    `json:"local_port"`
-	UsageCount int    `json:"-"`
+	Key        string      `json:"key"`
+	Env        string      `json:"env"`
+	Type       string      `json:"type"`
+	Endpoint   string      `json:"endpoint"`
+	Port       int         `json:"port"`
+	LocalPort  int         `json:"local_port"`
+	Usage      usageRecord `json:"-"`
+	Discovered bool        `json:"-"` // Found through RDS discovery rather than rds.json.
+	entry      int         // Index of the entry in rds.json, set by parseRDSConfig.
+}
+
+// ID returns the identifier used to reference this RDS configuration
+// from shortcuts and usage data, in the form "key|env|type".
+func (c RDSConfig) ID() string {
+	return fmt.Sprintf("%s|%s|%s", c.Key, c.Env, c.Type)
 }
 
 // Data Loading Functions
@@ -112,7 +168,7 @@ func loadLastShortcutKey() (string, error) {
 	return string(data), nil
 }
 
-func loadInstanceUsageData() (map[string]int, error) {
+func loadInstanceUsageData() (map[string]usageRecord, error) {
 	dataDir, err := GetDataDir()
 	if err != nil {
 		return nil, err
@@ -122,18 +178,18 @@ func loadInstanceUsageData() (map[string]int, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return make(map[string]int), nil
+			return make(map[string]usageRecord), nil
 		}
 		return nil, err
 	}
-	var usageMap map[string]int
+	var usageMap map[string]usageRecord
 	if err := json.Unmarshal(data, &usageMap); err != nil {
 		return nil, fmt.Errorf("file %s is corrupted: %w", filePath, err)
 	}
 	return usageMap, nil
 }
 
-func saveInstanceUsageData(data map[string]int) error {
+func saveInstanceUsageData(data map[string]usageRecord) error {
 	dataDir, err := GetDataDir()
 	if err != nil {
 		return err
@@ -150,27 +206,44 @@ func saveInstanceUsageData(data map[string]int) error {
 	return os.WriteFile(filePath, bytes, 0644)
 }
 
+// rdsWarningsOnce reports the problems of rds.json once per run.
+var rdsWarningsOnce sync.Once
+
+// loadRDSConfig reads rds.json from the configuration directory. Its
+// problems are reported, and the entries with errors left out.
 func loadRDSConfig() ([]RDSConfig, error) {
-	configDir, err := GetConfigDir()
+	filePath, err := rdsConfigPath()
 	if err != nil {
 		return nil, err
 	}
-	filePath := filepath.Join(configDir, "rds.json")
 
 	configFile, err := os.ReadFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read rds.json at %s; please ensure it exists or place a template in the app's config/ directory", filePath)
+		return nil, fmt.Errorf("failed to read rds.json at %s; please ensure it exists or place a template in the app's config/ directory: %w", filePath, err)
 	}
 
-	var rdsConfigs []RDSConfig
-	err = json.Unmarshal(configFile, &rdsConfigs)
+	rdsConfigs, problems, err := parseRDSConfig(configFile, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse %s: %w", filePath, err)
+		return nil, fmt.Errorf("invalid rds.json; run 'asmago config validate' for details: %w", err)
 	}
+	rdsWarningsOnce.Do(func() {
+		skipped := false
+		for _, problem := range problems {
+			if problem.Warning {
+				fmt.Fprintf(os.Stderr, Yellow("Warning: %v\n"), problem)
+				continue
+			}
+			fmt.Fprintf(os.Stderr, Red("Error: %v\n"), problem)
+			skipped = true
+		}
+		if skipped {
+			fmt.Fprintln(os.Stderr, Red("Entries of rds.json with errors are skipped until they are fixed."))
+		}
+	})
 	return rdsConfigs, nil
 }
 
-func loadRdsUsageData() (map[string]int, error) {
+func loadRdsUsageData() (map[string]usageRecord, error) {
 	dataDir, err := GetDataDir()
 	if err != nil {
 		return nil, err
@@ -180,18 +253,18 @@ func loadRdsUsageData() (map[string]int, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return make(map[string]int), nil
+			return make(map[string]usageRecord), nil
 		}
 		return nil, err
 	}
-	var usageMap map[string]int
+	var usageMap map[string]usageRecord
 	if err := json.Unmarshal(data, &usageMap); err != nil {
 		return nil, fmt.Errorf("file %s is corrupted: %w", filePath, err)
 	}
 	return usageMap, nil
 }
 
-func saveRdsUsageData(data map[string]int) error {
+func saveRdsUsageData(data map[string]usageRecord) error {
 	dataDir, err := GetDataDir()
 	if err != nil {
 		return err
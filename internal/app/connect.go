@@ -0,0 +1,256 @@
+package app
+
+import (
+	"strings"
+)
+
+// ConnectOptions holds the values used to resolve a connection without prompting.
+type ConnectOptions struct {
+	Profile  string
+	Region   string
+	Instance string // Instance ID or value of the Name tag.
+	Action   string // "ssm", "rds", "forward" or "port".
+	RDS      string // RDS target ID in the form "key|env|type", or a unique key.
+	Target   string // Remote host target ID, or a unique key, for the "forward" action.
+	Ports    string // Instance port pairs such as "8080,15005:5005" for the "port" action.
+	Env      string // Limits the RDS and remote host targets to this environment.
+	Name     string // Optional name to save the resulting shortcut under.
+}
+
+// Connect resolves a profile, instance and action from the given options and
+// runs the final action. It never opens a prompt: anything missing or
+// ambiguous is reported as an ExitError.
+func (a *App) Connect(opts ConnectOptions) error {
+	if opts.Profile == "" {
+		opts.Profile = defaultProfile()
+	}
+	if opts.Profile == "" {
+		return newExitError(ExitUsage, "--profile is required (or set AWS_PROFILE)")
+	}
+	if opts.Instance == "" {
+		return newExitError(ExitUsage, "--instance is required")
+	}
+
+	var action string
+	switch strings.ToLower(opts.Action) {
+	case "ssm":
+		action = actionSSM
+	case "rds":
+		action = actionRDS
+		if opts.RDS == "" {
+			return newExitError(ExitUsage, "--rds is required when --action is 'rds'")
+		}
+	case "forward":
+		action = actionForward
+		if opts.Target == "" {
+			return newExitError(ExitUsage, "--target is required when --action is 'forward'")
+		}
+	case "port":
+		action = actionInstancePort
+		if opts.Ports == "" {
+			return newExitError(ExitUsage, "--ports is required when --action is 'port'")
+		}
+	case "":
+		return newExitError(ExitUsage, "--action is required (ssm, rds, forward or port)")
+	default:
+		return newExitError(ExitUsage, "invalid --action '%s' (expected ssm, rds, forward or port)", opts.Action)
+	}
+
+	var portPairs []PortPair
+	if action == actionInstancePort {
+		var err error
+		portPairs, err = parsePortPairs(opts.Ports)
+		if err != nil {
+			return newExitError(ExitUsage, "invalid --ports: %v", err)
+		}
+	}
+
+	if err := resolveProfile(opts.Profile); err != nil {
+		return err
+	}
+
+	region, err := resolveRegion(opts.Profile, opts.Region)
+	if err != nil {
+		return err
+	}
+
+	instances, err := fetchRunningInstances(opts.Profile, region)
+	if err != nil {
+		return withExitCode(ExitAWS, err)
+	}
+	instance, err := resolveInstance(instances, opts.Instance)
+	if err != nil {
+		return err
+	}
+
+	if opts.Env == "" {
+		opts.Env = a.settings.profile(opts.Profile).Env
+	}
+	// The environment detected from the instance only narrows the lookup: a
+	// value found in no target of that environment is looked up in all of them.
+	var detectedEnv string
+	if opts.Env == "" {
+		detectedEnv = detectInstanceEnv(a.settings.envRules(), instance, opts.Profile)
+	}
+
+	var rdsID string
+	if action == actionRDS {
+		allConfigs, err := loadRDSTargets(opts.Profile, region, a.settings)
+		if err != nil {
+			return err
+		}
+		envOf := func(config RDSConfig) string { return config.Env }
+		allConfigs = filterByEnv(allConfigs, opts.Env, envOf)
+		rdsConfig, err := resolveRDSConfig(allConfigs, opts.RDS)
+		if detectedEnv != "" {
+			if detected, detectedErr := resolveRDSConfig(filterByEnv(allConfigs, detectedEnv, envOf), opts.RDS); ExitCode(detectedErr) != ExitNotFound {
+				rdsConfig, err = detected, detectedErr
+			}
+		}
+		if err != nil {
+			return err
+		}
+		rdsID = rdsConfig.ID()
+	}
+
+	var targetID string
+	if action == actionForward {
+		allTargets, err := loadTargets(opts.Profile, region, a.settings)
+		if err != nil {
+			return err
+		}
+		envOf := func(t Target) string { return t.Env }
+		allTargets = filterByEnv(allTargets, opts.Env, envOf)
+		target, err := resolveTarget(allTargets, opts.Target)
+		if detectedEnv != "" {
+			if detected, detectedErr := resolveTarget(filterByEnv(allTargets, detectedEnv, envOf), opts.Target); ExitCode(detectedErr) != ExitNotFound {
+				target, err = detected, detectedErr
+			}
+		}
+		if err != nil {
+			return err
+		}
+		targetID = target.ID()
+	}
+
+	shortcut := Shortcut{
+		Profile:      opts.Profile,
+		InstanceID:   instance.ID,
+		InstanceName: instance.displayName(),
+		Action:       action,
+		RDS_ID:       rdsID,
+		TargetID:     targetID,
+		PortPairs:    portPairs,
+		Name:         opts.Name,
+
+		AutoScalingGroup: instance.autoScalingGroup(),
+	}
+	if opts.Name != "" {
+		if err := a.shortcutMgr.validateName(shortcutKey(shortcut), opts.Name); err != nil {
+			return &ExitError{Code: ExitUsage, Err: err}
+		}
+	}
+	a.shortcutMgr.addOrUpdate(shortcut)
+
+	return withExitCode(ExitAWS, a.executeFinalAction(&shortcut, region))
+}
+
+// listAWSProfiles returns the profiles defined in the shared config and
+// credentials files, in the order they appear.
+func listAWSProfiles() ([]string, error) {
+	c, err := loadAWSSharedConfig()
+	if err != nil {
+		return nil, err
+	}
+	return c.names, nil
+}
+
+// resolveProfile checks that the given profile exists in the shared config
+// or credentials file.
+func resolveProfile(profile string) error {
+	_, err := loadProfileConfig(profile)
+	return err
+}
+
+// resolveRegion returns override when set, or the region from the environment
+// or the profile. Unlike getRegionForProfile, it never prompts.
+func resolveRegion(profile, override string) (string, error) {
+	if override != "" {
+		return override, nil
+	}
+	if region := profileRegion(profile); region != "" {
+		return region, nil
+	}
+	return "", newExitError(ExitUsage, "region is not configured for profile '%s'; pass --region", profile)
+}
+
+// resolveInstance finds exactly one instance whose ID or Name tag equals the given value.
+func resolveInstance(instances []EC2Instance, value string) (*EC2Instance, error) {
+	var matches []EC2Instance
+	for _, inst := range instances {
+		if inst.ID == value || (inst.Name != nil && *inst.Name == value) {
+			matches = append(matches, inst)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, newExitError(ExitNotFound, "no running instance found with ID or Name '%s'", value)
+	case 1:
+		return &matches[0], nil
+	default:
+		ids := make([]string, len(matches))
+		for i, inst := range matches {
+			ids[i] = inst.ID
+		}
+		return nil, newExitError(ExitAmbiguous, "instance '%s' is ambiguous, it matches: %s; pass an instance ID instead", value, strings.Join(ids, ", "))
+	}
+}
+
+// resolveRDSConfig finds the RDS configuration matching a full "key|env|type" ID,
+// or a bare key when only one configuration uses it.
+func resolveRDSConfig(allConfigs []RDSConfig, value string) (*RDSConfig, error) {
+	var matches []RDSConfig
+	for _, conf := range allConfigs {
+		if conf.ID() == value || conf.Key == value {
+			matches = append(matches, conf)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, newExitError(ExitNotFound, "no RDS configuration found for '%s'", value)
+	case 1:
+		return &matches[0], nil
+	default:
+		ids := make([]string, len(matches))
+		for i, conf := range matches {
+			ids[i] = conf.ID()
+		}
+		return nil, newExitError(ExitAmbiguous, "RDS target '%s' is ambiguous, it matches: %s; use the full key|env|type", value, strings.Join(ids, ", "))
+	}
+}
+
+// resolveTarget finds the remote host target matching a full target ID, or a
+// bare key when only one target uses it.
+func resolveTarget(allTargets []Target, value string) (*Target, error) {
+	var matches []Target
+	for _, t := range allTargets {
+		if t.ID() == value || t.Key == value {
+			matches = append(matches, t)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, newExitError(ExitNotFound, "no target found for '%s'", value)
+	case 1:
+		return &matches[0], nil
+	default:
+		ids := make([]string, len(matches))
+		for i, t := range matches {
+			ids[i] = t.ID()
+		}
+		return nil, newExitError(ExitAmbiguous, "target '%s' is ambiguous, it matches: %s; use the full target ID", value, strings.Join(ids, ", "))
+	}
+}
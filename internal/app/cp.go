@@ -0,0 +1,396 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Without scp, file transfers go through SSM Run Command, one chunk per
+// command. Downloaded chunks must fit, base64 encoded, in the output returned
+// by get-command-invocation; uploaded chunks are sent as command parameters.
+const (
+	downloadChunkSize = 17 * 1024
+	uploadChunkSize   = 24 * 1024
+	copyConcurrency   = 4 // Chunks transferred at once.
+)
+
+// CopyOptions holds the values of the 'cp' subcommand.
+type CopyOptions struct {
+	Source      string // Local path or host:path.
+	Destination string // Local path or host:path.
+	Profile     string // Required unless the host is a shortcut name.
+	Region      string
+	User        string // User to log in as with scp; ssh picks it when empty.
+}
+
+// remotePath is a path on an instance, written as host:path.
+type remotePath struct {
+	Host string
+	Path string
+}
+
+// parseRemotePath splits a host:path argument. Local paths, including
+// Windows paths such as C:\dump.txt, are reported with ok set to false.
+func parseRemotePath(arg string) (remotePath, bool) {
+	host, p, found := strings.Cut(arg, ":")
+	if !found || host == "" || strings.ContainsAny(host, `/\`) {
+		return remotePath{}, false
+	}
+	if len(host) == 1 && (strings.HasPrefix(p, `\`) || strings.HasPrefix(p, "/")) {
+		return remotePath{}, false // Drive letter.
+	}
+	return remotePath{Host: host, Path: p}, true
+}
+
+// Copy copies a file between the local machine and an instance. It uses scp
+// through 'asmago proxy' when scp is installed and can log in, and otherwise
+// sends the file in base64 chunks through SSM Run Command, which needs
+// neither SSH keys nor an open SSH port, verified with its SHA-256 checksum.
+func (a *App) Copy(opts CopyOptions) error {
+	src, srcRemote := parseRemotePath(opts.Source)
+	dst, dstRemote := parseRemotePath(opts.Destination)
+	switch {
+	case srcRemote && dstRemote:
+		return newExitError(ExitUsage, "copying between two instances is not supported")
+	case !srcRemote && !dstRemote:
+		return newExitError(ExitUsage, "one of the paths must be on an instance, as host:path")
+	}
+
+	remote := dst
+	if srcRemote {
+		remote = src
+	}
+	if remote.Path == "" {
+		return newExitError(ExitUsage, "missing path after '%s:'", remote.Host)
+	}
+	profile, region, instanceID, err := a.resolveHost(remote.Host, opts.Profile, opts.Region)
+	if err != nil {
+		return err
+	}
+	platform, err := instancePlatform(profile, region, instanceID)
+	if err != nil {
+		return withExitCode(ExitAWS, err)
+	}
+	if strings.EqualFold(platform, "windows") {
+		return newExitError(ExitUsage, "copying files is only supported on Linux and macOS instances")
+	}
+
+	t := &fileTransfer{profile: profile, region: region, instanceID: instanceID, dryRun: a.DryRun}
+	err = t.scp(opts.User, opts.Source, opts.Destination, srcRemote)
+	if !errors.Is(err, errSSHUnavailable) {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, Yellow("Warning: %v; copying through Run Command instead\n"), err)
+	if srcRemote {
+		return t.download(src.Path, opts.Destination)
+	}
+	return t.upload(opts.Source, dst.Path)
+}
+
+// fileTransfer copies a file to or from one instance.
+type fileTransfer struct {
+	profile    string
+	region     string
+	instanceID string
+	dryRun     bool
+}
+
+// errSSHUnavailable reports that scp cannot be used: it is not installed, or
+// ssh could not connect or log in. Only then is Run Command used instead,
+// since its commands run as root rather than as the user scp logs in as.
+var errSSHUnavailable = errors.New("scp is unavailable")
+
+// scp copies the file with scp, reaching the instance through 'asmago proxy'
+// with the resolved profile and region. A batch mode ssh probe runs first, so
+// that a failed connection or login, which returns errSSHUnavailable, is told
+// apart from a failure of the copy itself, such as a denied permission.
+func (t *fileTransfer) scp(user, source, destination string, download bool) error {
+	for _, name := range []string{"ssh", "scp"} {
+		if _, err := exec.LookPath(name); err != nil {
+			return fmt.Errorf("%w: %s was not found", errSSHUnavailable, name)
+		}
+	}
+	executablePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get application path: %w", err)
+	}
+	host := t.instanceID
+	if user != "" {
+		host = user + "@" + host
+	}
+	if download {
+		source = host + ":" + scpPath(strings.SplitN(source, ":", 2)[1])
+	} else {
+		destination = host + ":" + scpPath(strings.SplitN(destination, ":", 2)[1])
+	}
+
+	proxyCommand := fmt.Sprintf("%s proxy %s --profile %s --region %s --port %%p", sshQuote(executablePath), t.instanceID, sshQuote(t.profile), t.region)
+	options := []string{
+		"-o", "BatchMode=yes",
+		"-o", "ConnectTimeout=30",
+		"-o", "HostKeyAlias=" + t.instanceID,
+		"-o", "StrictHostKeyChecking=accept-new",
+		"-o", "ProxyCommand=" + proxyCommand,
+	}
+	probeArgs := append(slices.Clone(options), host, "true")
+	scpArgs := append(append([]string{"-q"}, options...), source, destination)
+	if t.dryRun {
+		fmt.Println(Cyan("\n-- DRY RUN MODE --"))
+		fmt.Println("Commands to be executed:")
+		fmt.Println(Yellow("ssh " + strings.Join(probeArgs, " ")))
+		fmt.Println(Yellow("scp " + strings.Join(scpArgs, " ")))
+		fmt.Println("If ssh cannot connect or log in, the file is copied through Run Command chunks instead.")
+		return nil
+	}
+
+	// ssh exits with 255 when it could not connect or log in, and otherwise
+	// with the status of the remote command.
+	probe := exec.Command("ssh", probeArgs...)
+	probe.Stderr = os.Stderr
+	if err := probe.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() == 255 {
+			return fmt.Errorf("%w: ssh through 'asmago proxy' could not connect or log in", errSSHUnavailable)
+		}
+	}
+
+	fmt.Printf(Cyan("Copying %s to %s with scp...\n"), source, destination)
+	cmd := exec.Command("scp", scpArgs...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("scp failed: %w", err)
+	}
+	fmt.Println(Green("✅ Copied with scp."))
+	return nil
+}
+
+// scpPath turns a remote path into the form scp expects: a leading ~/ becomes
+// a path relative to the home directory.
+func scpPath(p string) string {
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		return rest
+	}
+	return p
+}
+
+// run runs a shell command on the instance and returns its standard output.
+func (t *fileTransfer) run(command string, interrupted *atomic.Bool) (string, error) {
+	commandID, err := sendCommand(t.profile, t.region, t.instanceID, "", command)
+	if err != nil {
+		return "", err
+	}
+	inv, err := followCommandInvocation(t.profile, t.region, commandID, t.instanceID, interrupted, false)
+	if err != nil {
+		return "", err
+	}
+	if inv.Status != "Success" {
+		return "", fmt.Errorf("remote command %s (exit %d): %s", strings.ToLower(inv.Status), inv.ResponseCode, strings.TrimSpace(inv.StandardErrorContent))
+	}
+	return inv.StandardOutputContent, nil
+}
+
+// forEachChunk calls fn for every chunk index with a bounded number of
+// workers, printing progress, and returns the first error.
+func forEachChunk(count int, interrupted *atomic.Bool, fn func(i int) error) error {
+	var done atomic.Int32
+	var firstErr error
+	var errOnce sync.Once
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(copyConcurrency, count) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := fn(i); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						interrupted.Store(true)
+					})
+					continue
+				}
+				fmt.Printf("\r  %d/%d chunks", done.Add(1), count)
+			}
+		}()
+	}
+	for i := range count {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	fmt.Println()
+	return firstErr
+}
+
+// upload copies a local file to a path on the instance. A destination that is
+// an existing directory receives the file under its local name. The file is
+// read chunk by chunk rather than loaded at once.
+func (t *fileTransfer) upload(localPath, remote string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", localPath, err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", localPath, err)
+	}
+	sum := hash.Sum(nil)
+	chunks := int((size + uploadChunkSize - 1) / uploadChunkSize)
+	if t.dryRun {
+		fmt.Println(Cyan("\n-- DRY RUN MODE --"))
+		fmt.Printf("Would upload %s (%d bytes) to %s:%s in %d Run Command chunks.\n", localPath, size, t.instanceID, remote, chunks)
+		return nil
+	}
+
+	interrupted, stop := notifyInterrupt()
+	defer stop()
+
+	// Resolve the final path and create an empty temporary file next to it.
+	initCommand := fmt.Sprintf(`p=%s; if [ -d "$p" ]; then p="${p%%/}/"%s; fi; : > "$p.asmago-part" && printf '%%s' "$p"`, remoteShellPath(remote), shellQuote(filepath.Base(localPath)))
+	target, err := t.run(initCommand, interrupted)
+	if err != nil {
+		return withExitCode(ExitAWS, fmt.Errorf("failed to prepare %s: %w", remote, err))
+	}
+	part := target + ".asmago-part"
+
+	fmt.Printf(Cyan("⬆️  Uploading %s (%d bytes) to %s:%s...\n"), localPath, size, t.instanceID, target)
+	err = forEachChunk(chunks, interrupted, func(i int) error {
+		chunk := make([]byte, uploadChunkSize)
+		n, err := file.ReadAt(chunk, int64(i)*uploadChunkSize)
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read %s: %w", localPath, err)
+		}
+		chunk = chunk[:n]
+		command := fmt.Sprintf("printf '%%s' '%s' | base64 -d | dd of=%s bs=%d seek=%d conv=notrunc 2>/dev/null", base64.StdEncoding.EncodeToString(chunk), shellQuote(part), uploadChunkSize, i)
+		_, err = t.run(command, interrupted)
+		return err
+	})
+	if err != nil {
+		t.run("rm -f "+shellQuote(part), &atomic.Bool{})
+		return withExitCode(ExitAWS, fmt.Errorf("upload failed: %w", err))
+	}
+
+	finishCommand := fmt.Sprintf("%s && mv -f %s %s", remoteChecksumCommand(shellQuote(part)), shellQuote(part), shellQuote(target))
+	output, err := t.run(finishCommand, interrupted)
+	if err != nil {
+		return withExitCode(ExitAWS, fmt.Errorf("failed to finish upload: %w", err))
+	}
+	if remoteSum := strings.TrimSpace(output); remoteSum != hex.EncodeToString(sum) {
+		return fmt.Errorf("checksum mismatch after upload: local %x, remote %s", sum, remoteSum)
+	}
+	fmt.Printf(Green("✅ Uploaded %d bytes, SHA-256 verified.\n"), size)
+	return nil
+}
+
+// download copies a file from the instance to a local path. A destination
+// that is an existing directory receives the file under its remote name.
+func (t *fileTransfer) download(remote, localPath string) error {
+	if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+		localPath = filepath.Join(localPath, path.Base(remote))
+	}
+	if t.dryRun {
+		fmt.Println(Cyan("\n-- DRY RUN MODE --"))
+		fmt.Printf("Would download %s:%s to %s through Run Command chunks.\n", t.instanceID, remote, localPath)
+		return nil
+	}
+
+	interrupted, stop := notifyInterrupt()
+	defer stop()
+
+	statCommand := fmt.Sprintf("p=%s; [ -f \"$p\" ] || { echo \"$p: no such file\" >&2; exit 1; }; wc -c < \"$p\" | tr -d ' ' && %s", remoteShellPath(remote), remoteChecksumCommand(`"$p"`))
+	output, err := t.run(statCommand, interrupted)
+	if err != nil {
+		return withExitCode(ExitAWS, fmt.Errorf("failed to read %s: %w", remote, err))
+	}
+	fields := strings.Fields(output)
+	if len(fields) != 2 {
+		return fmt.Errorf("unexpected output while reading %s: %q", remote, output)
+	}
+	size, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return fmt.Errorf("unexpected size of %s: %q", remote, fields[0])
+	}
+	remoteSum := fields[1]
+
+	part := localPath + ".asmago-part"
+	file, err := os.Create(part)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", part, err)
+	}
+	defer os.Remove(part)
+
+	fmt.Printf(Cyan("⬇️  Downloading %s:%s (%d bytes) to %s...\n"), t.instanceID, remote, size, localPath)
+	chunks := (size + downloadChunkSize - 1) / downloadChunkSize
+	err = forEachChunk(chunks, interrupted, func(i int) error {
+		command := fmt.Sprintf("p=%s; dd if=\"$p\" bs=%d skip=%d count=1 2>/dev/null | base64 | tr -d '\\n'", remoteShellPath(remote), downloadChunkSize, i)
+		encoded, err := t.run(command, interrupted)
+		if err != nil {
+			return err
+		}
+		chunk, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return fmt.Errorf("chunk %d is corrupted: %w", i, err)
+		}
+		_, err = file.WriteAt(chunk, int64(i)*downloadChunkSize)
+		return err
+	})
+	if err != nil {
+		file.Close()
+		return withExitCode(ExitAWS, fmt.Errorf("download failed: %w", err))
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return err
+	}
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", part, err)
+	}
+	if localSum := hex.EncodeToString(hash.Sum(nil)); localSum != remoteSum {
+		return fmt.Errorf("checksum mismatch after download: remote %s, local %s", remoteSum, localSum)
+	}
+	if err := os.Rename(part, localPath); err != nil {
+		return fmt.Errorf("failed to move %s into place: %w", localPath, err)
+	}
+	fmt.Printf(Green("✅ Downloaded %d bytes, SHA-256 verified.\n"), size)
+	return nil
+}
+
+// remoteChecksumCommand prints the SHA-256 checksum of a file on Linux
+// (sha256sum) or macOS (shasum). quotedPath must already be shell quoted.
+func remoteChecksumCommand(quotedPath string) string {
+	return fmt.Sprintf("{ sha256sum %[1]s 2>/dev/null || shasum -a 256 %[1]s; } | cut -d' ' -f1", quotedPath)
+}
+
+// remoteShellPath quotes a remote path for the shell, keeping a leading ~/
+// relative to the home directory of the user running the command.
+func remoteShellPath(p string) string {
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		return `"$HOME"/` + shellQuote(rest)
+	}
+	return shellQuote(p)
+}
+
+// shellQuote quotes a string for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// envRule maps instances to an environment. Match selects the value the rule
+// looks at: "name" (the Name tag), "tag:<Key>" or "profile". That value must
+// match the Glob or the Regex of the rule; with Regex, Env may refer to
+// capture groups such as "$1".
+type envRule struct {
+	Match string `yaml:"match"`
+	Glob  string `yaml:"glob"`
+	Regex string `yaml:"regex"`
+	Env   string `yaml:"env"`
+
+	re *regexp.Regexp
+}
+
+// defaultEnvRules are used when config.yaml has no env_rules.
+var defaultEnvRules = []envRule{
+	{Match: "name", Glob: "dev*", Env: "dev"},
+	{Match: "name", Glob: "qa*", Env: "qa"},
+}
+
+// envRules returns the env_rules of the settings, or the default rules.
+func (s Settings) envRules() []envRule {
+	if s.EnvRules == nil {
+		return defaultEnvRules
+	}
+	return s.EnvRules
+}
+
+// compile checks the rule and compiles its regular expression.
+func (r *envRule) compile() error {
+	switch {
+	case r.Match == "name", r.Match == "profile":
+	case strings.HasPrefix(r.Match, "tag:") && len(r.Match) > len("tag:"):
+	default:
+		return fmt.Errorf("match must be 'name', 'profile' or 'tag:<Key>', got '%s'", r.Match)
+	}
+	if r.Env == "" {
+		return fmt.Errorf("env is missing")
+	}
+	switch {
+	case r.Glob != "" && r.Regex != "":
+		return fmt.Errorf("set either glob or regex, not both")
+	case r.Glob != "":
+		if _, err := path.Match(r.Glob, ""); err != nil {
+			return fmt.Errorf("invalid glob '%s': %w", r.Glob, err)
+		}
+	case r.Regex != "":
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid regex '%s': %w", r.Regex, err)
+		}
+		r.re = re
+	default:
+		return fmt.Errorf("glob or regex is missing")
+	}
+	return nil
+}
+
+// String describes the rule, e.g. "name glob dev* -> dev".
+func (r envRule) String() string {
+	if r.Regex != "" {
+		return fmt.Sprintf("%s regex %s -> %s", r.Match, r.Regex, r.Env)
+	}
+	return fmt.Sprintf("%s glob %s -> %s", r.Match, r.Glob, r.Env)
+}
+
+// apply returns the environment of an instance if the rule matches it.
+func (r *envRule) apply(instance *EC2Instance, profile string) (string, bool) {
+	var value string
+	switch {
+	case r.Match == "name":
+		if instance == nil || instance.Name == nil {
+			return "", false
+		}
+		value = *instance.Name
+	case r.Match == "profile":
+		value = profile
+	default:
+		if instance == nil {
+			return "", false
+		}
+		v, ok := instance.Tags[strings.TrimPrefix(r.Match, "tag:")]
+		if !ok {
+			return "", false
+		}
+		value = v
+	}
+
+	if r.re == nil {
+		matched, _ := path.Match(r.Glob, value)
+		return r.Env, matched
+	}
+	match := r.re.FindStringSubmatchIndex(value)
+	if match == nil {
+		return "", false
+	}
+	return string(r.re.ExpandString(nil, r.Env, value, match)), true
+}
+
+// detectInstanceEnv returns the environment of an instance according to the
+// first matching rule, or an empty string when no rule matches.
+func detectInstanceEnv(rules []envRule, instance *EC2Instance, profile string) string {
+	for i := range rules {
+		if env, ok := rules[i].apply(instance, profile); ok && env != "" {
+			return env
+		}
+	}
+	return ""
+}
+
+// targetEnv returns the environment used to filter the targets offered for an
+// instance: override when set, then the env preferred for the profile, then
+// the one detected by the rules.
+func targetEnv(settings Settings, instance *EC2Instance, profile, override string) string {
+	if override == "" {
+		override = settings.profile(profile).Env
+	}
+	if override != "" {
+		fmt.Printf(Cyan("ℹ️  Showing targets for '%s' env...\n"), override)
+		return override
+	}
+	env := detectInstanceEnv(settings.envRules(), instance, profile)
+	if env != "" {
+		fmt.Printf(Cyan("ℹ️  '%s' instance detected, showing targets for '%s' env...\n"), env, env)
+	}
+	return env
+}
+
+// filterByEnv returns the items of the given environment, or all items when
+// env is empty.
+func filterByEnv[T any](items []T, env string, envOf func(T) string) []T {
+	if env == "" {
+		return items
+	}
+	var filtered []T
+	for _, item := range items {
+		if strings.EqualFold(envOf(item), env) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Exit codes returned by the non-interactive commands.
+const (
+	ExitFailure   = 1 // Generic failure.
+	ExitUsage     = 2 // A required flag is missing or has an invalid value.
+	ExitNotFound  = 3 // A profile, instance or RDS target could not be found.
+	ExitAmbiguous = 4 // A value matches more than one profile, instance or RDS target.
+	ExitAWS       = 5 // An AWS API call or the AWS CLI returned an error.
+	ExitRemote    = 6 // A command run with 'exec' exited with a non-zero status.
+)
+
+// ExitError is an error that carries the process exit code to use when it
+// reaches the command layer.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// newExitError creates an ExitError with a formatted message.
+func newExitError(code int, format string, a ...any) error {
+	return &ExitError{Code: code, Err: fmt.Errorf(format, a...)}
+}
+
+// withExitCode wraps err in an ExitError with the given code, unless it
+// already carries one.
+func withExitCode(code int, err error) error {
+	var exitErr *ExitError
+	if err == nil || errors.As(err, &exitErr) {
+		return err
+	}
+	return &ExitError{Code: code, Err: err}
+}
+
+// ExitCode returns the exit code for an error. Errors that are not an
+// ExitError map to ExitFailure.
+func ExitCode(err error) int {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return ExitFailure
+}
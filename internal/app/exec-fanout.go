@@ -0,0 +1,247 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// defaultExecConcurrency is how many instances run a tag-targeted command at once
+// when no limit is given.
+const defaultExecConcurrency = 10
+
+// execResult is the outcome of a command on one instance.
+type execResult struct {
+	Instance EC2Instance
+	Status   string // Final Run Command status, e.g. "Success" or "Failed".
+	Code     int    // Exit status of the command, -1 when it did not run.
+	Stdout   string
+	Stderr   string
+	Err      error // Set when the command could not be sent or followed.
+}
+
+// failed reports whether the command did not succeed on the instance.
+func (r execResult) failed() bool {
+	return r.Err != nil || r.Status != "Success"
+}
+
+// summary returns the outcome shown in the header of a result group.
+func (r execResult) summary() string {
+	switch {
+	case r.Err != nil:
+		return "error: " + r.Err.Error()
+	case r.Status == "Success":
+		return "exit 0"
+	case r.Code > 0:
+		return fmt.Sprintf("exit %d", r.Code)
+	default:
+		return strings.ToLower(r.Status)
+	}
+}
+
+// parseTagFilters converts "Key=Value" arguments into DescribeInstances
+// filters. A value may list several alternatives separated by commas.
+func parseTagFilters(tags []string) ([]ec2types.Filter, error) {
+	filters := make([]ec2types.Filter, len(tags))
+	for i, tag := range tags {
+		key, value, found := strings.Cut(tag, "=")
+		if !found || key == "" || value == "" {
+			return nil, fmt.Errorf("invalid tag '%s' (expected Key=Value)", tag)
+		}
+		filters[i] = ec2types.Filter{Name: aws.String("tag:" + key), Values: strings.Split(value, ",")}
+	}
+	return filters, nil
+}
+
+// execOnTaggedInstances runs a command on every running instance matching the
+// tag filters, at most opts.Concurrency at a time, then prints the results
+// grouped by identical output. It fails if the command failed anywhere.
+func (a *App) execOnTaggedInstances(opts ExecOptions) error {
+	filters, err := parseTagFilters(opts.Tags)
+	if err != nil {
+		return &ExitError{Code: ExitUsage, Err: err}
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultExecConcurrency
+	}
+	command := strings.Join(opts.Command, " ")
+
+	region, instances, err := resolveTaggedInstances(opts.Profile, opts.Region, filters)
+	if err != nil {
+		return err
+	}
+
+	if a.DryRun {
+		fmt.Fprintln(os.Stderr, Cyan("\n-- DRY RUN MODE --"))
+		fmt.Fprintln(os.Stderr, "Commands to be executed:")
+		for _, inst := range instances {
+			args, err := sendCommandArgs(opts.Profile, region, inst.ID, platformOf(inst), command)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, Yellow("aws "+strings.Join(args, " ")))
+		}
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, Cyan("ℹ️  Running on %d instances, %d at a time...\n"), len(instances), concurrency)
+	results := runOnInstances(opts.Profile, region, instances, command, concurrency)
+	printGroupedResults(results)
+
+	var failed, exited int
+	for _, r := range results {
+		if r.failed() {
+			failed++
+		}
+		if r.Err == nil && r.Code > 0 {
+			exited++
+		}
+	}
+	if failed > 0 {
+		code := ExitFailure
+		if exited == failed {
+			code = ExitRemote
+		}
+		return newExitError(code, "command failed on %d of %d instances", failed, len(results))
+	}
+	fmt.Fprintf(os.Stderr, Green("✅ Command succeeded on all %d instances.\n"), len(results))
+	return nil
+}
+
+// resolveTaggedInstances returns the region and the running instances
+// matching the filters, sorted by name.
+func resolveTaggedInstances(profile, regionOverride string, filters []ec2types.Filter) (string, []EC2Instance, error) {
+	if err := resolveProfile(profile); err != nil {
+		return "", nil, err
+	}
+	region, err := resolveRegion(profile, regionOverride)
+	if err != nil {
+		return "", nil, err
+	}
+	instances, err := queryRunningInstances(profile, region, filters)
+	if err != nil {
+		return "", nil, withExitCode(ExitAWS, err)
+	}
+	if len(instances) == 0 {
+		return "", nil, newExitError(ExitNotFound, "no running instances match the given tags in region %s", region)
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		if instances[i].displayName() != instances[j].displayName() {
+			return instances[i].displayName() < instances[j].displayName()
+		}
+		return instances[i].ID < instances[j].ID
+	})
+	return region, instances, nil
+}
+
+// platformOf returns the platform of an instance, "windows" or empty.
+func platformOf(inst EC2Instance) string {
+	if inst.Platform == nil {
+		return ""
+	}
+	return *inst.Platform
+}
+
+// runOnInstances runs a command on the instances with a bounded number of
+// workers and returns the results in the order of instances. Ctrl+C cancels
+// the commands still running.
+func runOnInstances(profile, region string, instances []EC2Instance, command string, concurrency int) []execResult {
+	interrupted, stop := notifyInterrupt()
+	defer stop()
+
+	results := make([]execResult, len(instances))
+	jobs := make(chan int)
+	var done atomic.Int32
+	var wg sync.WaitGroup
+	for range min(concurrency, len(instances)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = runOnInstance(profile, region, instances[i], command, interrupted)
+				n := done.Add(1)
+				fmt.Fprintf(os.Stderr, "[%d/%d] %s (%s): %s\n", n, len(instances), instances[i].displayName(), instances[i].ID, results[i].summary())
+			}
+		}()
+	}
+	for i := range instances {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// runOnInstance sends a command to one instance and waits for its result.
+func runOnInstance(profile, region string, inst EC2Instance, command string, interrupted *atomic.Bool) execResult {
+	result := execResult{Instance: inst, Code: -1}
+	if interrupted.Load() {
+		result.Err = fmt.Errorf("not run, interrupted")
+		return result
+	}
+	commandID, err := sendCommand(profile, region, inst.ID, platformOf(inst), command)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	inv, err := followCommandInvocation(profile, region, commandID, inst.ID, interrupted, false)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Status = inv.Status
+	result.Code = inv.ResponseCode
+	result.Stdout = inv.StandardOutputContent
+	result.Stderr = inv.StandardErrorContent
+	return result
+}
+
+// printGroupedResults prints the results, merging instances that produced
+// the same outcome and output. Groups are shown in order of first appearance.
+func printGroupedResults(results []execResult) {
+	type group struct {
+		result    execResult
+		instances []string
+	}
+	var groups []*group
+	byKey := make(map[string]*group)
+	for _, r := range results {
+		key := strings.Join([]string{r.summary(), r.Stdout, r.Stderr}, "\x00")
+		g, ok := byKey[key]
+		if !ok {
+			g = &group{result: r}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.instances = append(g.instances, fmt.Sprintf("%s (%s)", r.Instance.displayName(), r.Instance.ID))
+	}
+
+	for _, g := range groups {
+		header := fmt.Sprintf("\n=== %d instance(s), %s: %s ===", len(g.instances), g.result.summary(), strings.Join(g.instances, ", "))
+		if g.result.failed() {
+			fmt.Println(Red(header))
+		} else {
+			fmt.Println(Green(header))
+		}
+		if g.result.Stdout != "" {
+			fmt.Print(g.result.Stdout)
+			if !strings.HasSuffix(g.result.Stdout, "\n") {
+				fmt.Println()
+			}
+		}
+		if g.result.Stderr != "" {
+			fmt.Println(Yellow("--- stderr ---"))
+			fmt.Print(g.result.Stderr)
+			if !strings.HasSuffix(g.result.Stderr, "\n") {
+				fmt.Println()
+			}
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// commandPollInterval is how often the result of a command is polled.
+const commandPollInterval = 2 * time.Second
+
+// maxCommandOutput is the size at which SSM truncates the output returned by
+// get-command-invocation.
+const maxCommandOutput = 24000
+
+// ExecOptions holds the values used to run a command on an instance.
+type ExecOptions struct {
+	Profile     string
+	Region      string
+	Instance    string   // Instance ID or value of the Name tag.
+	Tags        []string // "Key=Value" tag filters selecting several instances instead.
+	Concurrency int      // Number of instances running the command at once with Tags.
+	Command     []string // Command and arguments, joined with spaces.
+}
+
+// commandInvocation is the part of a GetCommandInvocation response used to
+// follow a command.
+type commandInvocation struct {
+	Status                string
+	StatusDetails         string
+	ResponseCode          int
+	StandardOutputContent string
+	StandardErrorContent  string
+}
+
+// Exec runs a command on an instance, or on every instance matching the
+// tag filters, with SSM Run Command and prints its output. Like Connect, it
+// never prompts. The command's standard output is written to standard
+// output; everything else goes to standard error.
+func (a *App) Exec(opts ExecOptions) error {
+	if opts.Profile == "" {
+		opts.Profile = defaultProfile()
+	}
+	if opts.Profile == "" {
+		return newExitError(ExitUsage, "--profile is required (or set AWS_PROFILE)")
+	}
+	if opts.Instance == "" && len(opts.Tags) == 0 {
+		return newExitError(ExitUsage, "--instance or --tag is required")
+	}
+	if opts.Instance != "" && len(opts.Tags) > 0 {
+		return newExitError(ExitUsage, "--instance and --tag cannot be used together")
+	}
+	if len(opts.Command) == 0 {
+		return newExitError(ExitUsage, "a command is required after '--'")
+	}
+	if len(opts.Tags) > 0 {
+		return a.execOnTaggedInstances(opts)
+	}
+
+	region, instance, err := a.resolveExecTarget(opts)
+	if err != nil {
+		return err
+	}
+
+	return a.runRemoteCommand(opts.Profile, region, instance.ID, platformOf(*instance), strings.Join(opts.Command, " "))
+}
+
+// resolveExecTarget resolves the region and instance to run a command on.
+func (a *App) resolveExecTarget(opts ExecOptions) (string, *EC2Instance, error) {
+	if err := resolveProfile(opts.Profile); err != nil {
+		return "", nil, err
+	}
+	region, err := resolveRegion(opts.Profile, opts.Region)
+	if err != nil {
+		return "", nil, err
+	}
+	instances, err := fetchRunningInstances(opts.Profile, region)
+	if err != nil {
+		return "", nil, withExitCode(ExitAWS, err)
+	}
+	instance, err := resolveInstance(instances, opts.Instance)
+	if err != nil {
+		return "", nil, err
+	}
+	return region, instance, nil
+}
+
+// runCommandAction runs the command saved in a Run Command shortcut.
+func (a *App) runCommandAction(sc *Shortcut, region string) error {
+	if a.Background {
+		return newExitError(ExitUsage, "'%s' cannot run in the background", actionRunCommand)
+	}
+	if sc.Command == "" {
+		return fmt.Errorf("shortcut has no command to run")
+	}
+	platform, err := instancePlatform(sc.Profile, region, sc.InstanceID)
+	if err != nil {
+		return err
+	}
+	return a.runRemoteCommand(sc.Profile, region, sc.InstanceID, platform, sc.Command)
+}
+
+// instancePlatform returns the platform of an instance, "windows" for
+// Windows instances and an empty string otherwise.
+func instancePlatform(profile, region, instanceID string) (string, error) {
+	var platform string
+	err := callAWS(profile, region, func(ctx context.Context, cfg aws.Config) error {
+		output, err := ec2.NewFromConfig(cfg).DescribeInstances(ctx, &ec2.DescribeInstancesInput{InstanceIds: []string{instanceID}})
+		if err != nil {
+			return err
+		}
+		for _, reservation := range output.Reservations {
+			for _, inst := range reservation.Instances {
+				platform = string(inst.Platform)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get the platform of instance %s: %w", instanceID, err)
+	}
+	return platform, nil
+}
+
+// runCommandDocument returns the SSM document that runs a shell command on the platform.
+func runCommandDocument(platform string) string {
+	if strings.EqualFold(platform, "windows") {
+		return "AWS-RunPowerShellScript"
+	}
+	return "AWS-RunShellScript"
+}
+
+// sendCommandArgs returns the AWS CLI equivalent of sendCommand, shown in dry run mode.
+func sendCommandArgs(profile, region, instanceID, platform, command string) ([]string, error) {
+	parameters, err := json.Marshal(map[string][]string{"commands": {command}})
+	if err != nil {
+		return nil, err
+	}
+	return []string{"ssm", "send-command", "--instance-ids", instanceID, "--document-name", runCommandDocument(platform), "--parameters", string(parameters), "--profile", profile, "--region", region}, nil
+}
+
+// sendCommand sends a shell command to an instance and returns the command ID.
+func sendCommand(profile, region, instanceID, platform, command string) (string, error) {
+	var commandID string
+	err := callAWS(profile, region, func(ctx context.Context, cfg aws.Config) error {
+		output, err := ssm.NewFromConfig(cfg).SendCommand(ctx, &ssm.SendCommandInput{
+			InstanceIds:  []string{instanceID},
+			DocumentName: aws.String(runCommandDocument(platform)),
+			Parameters:   map[string][]string{"commands": {command}},
+		})
+		if err != nil {
+			return err
+		}
+		commandID = aws.ToString(output.Command.CommandId)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to send command: %w", err)
+	}
+	return commandID, nil
+}
+
+// runRemoteCommand sends a command to an instance and follows it until it
+// completes, printing its output as it becomes available. A command that
+// exits with a non-zero status is reported as an ExitError with ExitRemote,
+// after its status is printed, so it cannot be mistaken for asmago's codes.
+func (a *App) runRemoteCommand(profile, region, instanceID, platform, command string) error {
+	if a.DryRun {
+		args, err := sendCommandArgs(profile, region, instanceID, platform, command)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, Cyan("\n-- DRY RUN MODE --"))
+		fmt.Fprintln(os.Stderr, "Command to be executed:")
+		fmt.Fprintln(os.Stderr, Yellow("aws "+strings.Join(args, " ")))
+		return nil
+	}
+
+	commandID, err := sendCommand(profile, region, instanceID, platform, command)
+	if err != nil {
+		return withExitCode(ExitAWS, err)
+	}
+	fmt.Fprintf(os.Stderr, Cyan("ℹ️  Command %s sent to %s, waiting for output...\n"), commandID, instanceID)
+
+	interrupted, stop := notifyInterrupt()
+	defer stop()
+	inv, err := followCommandInvocation(profile, region, commandID, instanceID, interrupted, true)
+	if err != nil {
+		return withExitCode(ExitAWS, err)
+	}
+
+	switch {
+	case inv.Status == "Success":
+		fmt.Fprintln(os.Stderr, Green("✅ Exit status: 0"))
+		return nil
+	case inv.ResponseCode > 0:
+		fmt.Fprintf(os.Stderr, Red("Exit status: %d\n"), inv.ResponseCode)
+		return newExitError(ExitRemote, "command exited with status %d on %s", inv.ResponseCode, instanceID)
+	default:
+		return newExitError(ExitAWS, "command %s on %s: %s", strings.ToLower(inv.Status), instanceID, inv.StatusDetails)
+	}
+}
+
+// notifyInterrupt reports Ctrl+C and SIGTERM through the returned flag until
+// stop is called.
+func notifyInterrupt() (*atomic.Bool, func()) {
+	var interrupted atomic.Bool
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for range signals {
+			interrupted.Store(true)
+		}
+	}()
+	return &interrupted, func() { signal.Stop(signals) }
+}
+
+// followCommandInvocation polls a command until it reaches a final status.
+// With stream set, new standard output and standard error are printed as
+// they arrive. An interrupt cancels the command.
+func followCommandInvocation(profile, region, commandID, instanceID string, interrupted *atomic.Bool, stream bool) (*commandInvocation, error) {
+	var printedOut, printedErr int
+	for {
+		if !sleepUnlessInterrupted(commandPollInterval, interrupted) {
+			if stream {
+				fmt.Fprintln(os.Stderr, Yellow("\n⚠️  Cancelling command..."))
+			}
+			return nil, cancelCommand(profile, region, commandID, instanceID)
+		}
+
+		inv, err := getCommandInvocation(profile, region, commandID, instanceID)
+		if err != nil {
+			return nil, err
+		}
+		if inv == nil {
+			continue // Not registered yet.
+		}
+
+		running := false
+		switch inv.Status {
+		case "Pending", "InProgress", "Delayed", "Cancelling":
+			running = true
+		}
+		if !stream {
+			if running {
+				continue
+			}
+			return inv, nil
+		}
+
+		if len(inv.StandardOutputContent) > printedOut {
+			fmt.Print(inv.StandardOutputContent[printedOut:])
+			printedOut = len(inv.StandardOutputContent)
+			if !running && !strings.HasSuffix(inv.StandardOutputContent, "\n") {
+				fmt.Println()
+			}
+		}
+		if len(inv.StandardErrorContent) > printedErr {
+			fmt.Fprint(os.Stderr, inv.StandardErrorContent[printedErr:])
+			printedErr = len(inv.StandardErrorContent)
+		}
+		if running {
+			continue
+		}
+
+		if printedOut >= maxCommandOutput || printedErr >= maxCommandOutput {
+			fmt.Fprintf(os.Stderr, Yellow("\n⚠️  Output was truncated by SSM after %d characters.\n"), maxCommandOutput)
+		}
+		return inv, nil
+	}
+}
+
+// cancelCommand cancels a command on an instance and reports it as an error.
+func cancelCommand(profile, region, commandID, instanceID string) error {
+	err := callAWS(profile, region, func(ctx context.Context, cfg aws.Config) error {
+		_, err := ssm.NewFromConfig(cfg).CancelCommand(ctx, &ssm.CancelCommandInput{CommandId: aws.String(commandID), InstanceIds: []string{instanceID}})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cancel command %s: %w", commandID, err)
+	}
+	return newExitError(ExitFailure, "command %s cancelled", commandID)
+}
+
+// getCommandInvocation returns the current state of a command on an instance,
+// or nil if SSM has not registered the invocation yet.
+func getCommandInvocation(profile, region, commandID, instanceID string) (*commandInvocation, error) {
+	var inv *commandInvocation
+	err := callAWS(profile, region, func(ctx context.Context, cfg aws.Config) error {
+		output, err := ssm.NewFromConfig(cfg).GetCommandInvocation(ctx, &ssm.GetCommandInvocationInput{CommandId: aws.String(commandID), InstanceId: aws.String(instanceID)})
+		var notYet *ssmtypes.InvocationDoesNotExist
+		if errors.As(err, &notYet) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		inv = &commandInvocation{
+			Status:                string(output.Status),
+			StatusDetails:         aws.ToString(output.StatusDetails),
+			ResponseCode:          int(output.ResponseCode),
+			StandardOutputContent: aws.ToString(output.StandardOutputContent),
+			StandardErrorContent:  aws.ToString(output.StandardErrorContent),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get command output: %w", err)
+	}
+	return inv, nil
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// maxRecordedUses is the number of most recent use timestamps kept per item.
+// Only these uses are weighted by their age; older uses count as stale.
+const maxRecordedUses = 10
+
+// staleWeight is the weight of a use that is no longer recorded, or that was
+// counted before timestamps existed. maxStaleScore caps what all of them add
+// up to, so a long history never outranks a single use in the last month.
+const (
+	staleWeight   = 1
+	maxStaleScore = 40
+)
+
+// usageRecord tracks how often and how recently something was used.
+type usageRecord struct {
+	Count int         `json:"count"`
+	Uses  []time.Time `json:"uses,omitempty"` // Oldest first.
+}
+
+// UnmarshalJSON accepts both the current object form and the plain usage
+// count written by older versions.
+func (r *usageRecord) UnmarshalJSON(data []byte) error {
+	var count int
+	if err := json.Unmarshal(data, &count); err == nil {
+		*r = usageRecord{Count: count}
+		return nil
+	}
+	type plain usageRecord
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+// recordUse counts one more use at the given time.
+func (r *usageRecord) recordUse(now time.Time) {
+	r.Count++
+	r.Uses = append(r.Uses, now)
+	if len(r.Uses) > maxRecordedUses {
+		r.Uses = r.Uses[len(r.Uses)-maxRecordedUses:]
+	}
+}
+
+// score returns the frecency of the record. Like Firefox's frecency, each
+// recorded use adds a weight that decays with its age, so items used
+// regularly now rise above items that were used often a long time ago.
+// Uses without a timestamp only add staleWeight each, up to maxStaleScore.
+func (r usageRecord) score(now time.Time) float64 {
+	var total float64
+	for _, t := range r.Uses {
+		total += ageWeight(now.Sub(t))
+	}
+	stale := max(r.Count-len(r.Uses), 0)
+	return total + min(float64(stale*staleWeight), maxStaleScore)
+}
+
+// ageWeight returns the weight of a single use that happened age ago. A
+// negative age, from a clock that went back, counts as a recent use.
+func ageWeight(age time.Duration) float64 {
+	const day = 24 * time.Hour
+	switch {
+	case age <= 4*day:
+		return 100
+	case age <= 14*day:
+		return 70
+	case age <= 31*day:
+		return 50
+	case age <= 90*day:
+		return 30
+	default:
+		return 10
+	}
+}
+
+// sortByFrecency sorts items by the frecency of their usage record, highest
+// first. Ties keep the existing order.
+func sortByFrecency[T any](items []T, usage func(T) usageRecord) {
+	now := time.Now()
+	sort.SliceStable(items, func(i, j int) bool {
+		return usage(items[i]).score(now) > usage(items[j]).score(now)
+	})
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUsageRecordScoreOrder(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	// uses returns a record of n uses, one per day, the most recent one
+	// age ago.
+	uses := func(n int, age time.Duration) usageRecord {
+		var r usageRecord
+		for i := n - 1; i >= 0; i-- {
+			r.recordUse(now.Add(-age - time.Duration(i)*day))
+		}
+		return r
+	}
+
+	tests := []struct {
+		name          string
+		higher, lower usageRecord
+	}{
+		{"7 uses this week beat 200 uses last year", uses(7, 0), uses(200, 365*day)},
+		{"7 uses this week beat 200 uses without timestamps", uses(7, 0), usageRecord{Count: 200}},
+		{"1 use today beats 2000 uses without timestamps", uses(1, 0), usageRecord{Count: 2000}},
+		{"1 use today beats 1 use last month", uses(1, 0), uses(1, 30*day)},
+		{"10 uses last month beat 2 uses last month", uses(10, 20*day), uses(2, 20*day)},
+		{"200 uses last year beat 20 uses last year", uses(200, 365*day), uses(20, 365*day)},
+		{"5 uses without timestamps beat none", usageRecord{Count: 5}, usageRecord{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			higher, lower := tt.higher.score(now), tt.lower.score(now)
+			if higher <= lower {
+				t.Errorf("score %v, want more than %v", higher, lower)
+			}
+		})
+	}
+}
+
+func TestUsageRecordUnmarshalLegacyCount(t *testing.T) {
+	var r usageRecord
+	if err := r.UnmarshalJSON([]byte("42")); err != nil {
+		t.Fatal(err)
+	}
+	if r.Count != 42 || len(r.Uses) != 0 {
+		t.Errorf("got %+v, want a count of 42 and no uses", r)
+	}
+}
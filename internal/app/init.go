@@ -1,10 +1,12 @@
 package app
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // ensureUserConfigExists checks if rds.json exists in the user's
@@ -18,12 +20,7 @@ func ensureUserConfigExists() error {
 	}
 	targetPath := filepath.Join(targetConfigDir, "rds.json")
 
-	// 2. Check if the destination file already exists. If so, do nothing.
-	if _, err := os.Stat(targetPath); err == nil {
-		return nil // File already exists, job done.
-	}
-
-	// 3. Destination file does not exist. Find the source file near the executable.
+	// 2. Find the source file near the executable.
 	// Get the path where this binary/exe is located.
 	executablePath, err := os.Executable()
 	if err != nil {
@@ -34,6 +31,13 @@ func ensureUserConfigExists() error {
 	// Determine the source path (e.g., /path/to/asmago/config/rds.json)
 	sourcePath := filepath.Join(executableDir, "config", "rds.json")
 
+	// 3. Check if the destination file already exists. If so, only warn when
+	// the bundled copy was changed after it, since those edits are not used.
+	if targetInfo, err := os.Stat(targetPath); err == nil {
+		warnIfBundledConfigNewer(sourcePath, targetPath, targetInfo.ModTime())
+		return nil // File already exists, job done.
+	}
+
 	// 4. Check if the source file actually exists.
 	sourceFile, err := os.Open(sourcePath)
 	if err != nil {
@@ -44,7 +48,7 @@ func ensureUserConfigExists() error {
 	defer sourceFile.Close()
 
 	// 5. Perform the copy.
-	fmt.Println(Cyan("'rds.json' configuration file not found. Copying template..."))
+	fmt.Fprintln(os.Stderr, Cyan("'rds.json' configuration file not found. Copying template..."))
 
 	// Ensure the destination directory exists.
 	if err := os.MkdirAll(targetConfigDir, 0755); err != nil {
@@ -63,6 +67,24 @@ func ensureUserConfigExists() error {
 		return fmt.Errorf("failed to copy config file: %w", err)
 	}
 
-	fmt.Printf(Green("✅ Configuration successfully copied to: %s\n"), targetPath)
+	fmt.Fprintf(os.Stderr, Green("✅ Configuration successfully copied to: %s\n"), targetPath)
 	return nil
 }
+
+// warnIfBundledConfigNewer warns when the rds.json next to the executable is
+// newer than the user's copy and differs from it.
+func warnIfBundledConfigNewer(sourcePath, targetPath string, targetModTime time.Time) {
+	sourceInfo, err := os.Stat(sourcePath)
+	if err != nil || !sourceInfo.ModTime().After(targetModTime) {
+		return
+	}
+	source, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return
+	}
+	target, err := os.ReadFile(targetPath)
+	if err != nil || bytes.Equal(source, target) {
+		return
+	}
+	fmt.Fprintf(os.Stderr, Yellow("Warning: %s is newer than %s and is not used; apply its changes with 'asmago rds add/edit' or copy it over\n"), sourcePath, targetPath)
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PortPair maps a local port to a port on the instance itself.
+type PortPair struct {
+	Local  int `json:"local"`
+	Remote int `json:"remote"`
+}
+
+// String returns the pair as "local:remote", or just the port when both are equal.
+func (p PortPair) String() string {
+	if p.Local == p.Remote {
+		return strconv.Itoa(p.Remote)
+	}
+	return fmt.Sprintf("%d:%d", p.Local, p.Remote)
+}
+
+// formatPortPairs returns the pairs in the form accepted by parsePortPairs.
+func formatPortPairs(pairs []PortPair) string {
+	parts := make([]string, len(pairs))
+	for i, p := range pairs {
+		parts[i] = p.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// parsePortPairs parses a comma or space separated list of port pairs. Each
+// pair is either "port", forwarding the same local port, or "local:remote",
+// e.g. "8080,15005:5005".
+func parsePortPairs(value string) ([]PortPair, error) {
+	fields := strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("at least one port is required")
+	}
+
+	var pairs []PortPair
+	usedLocal := make(map[int]bool)
+	for _, field := range fields {
+		localStr, remoteStr, found := strings.Cut(field, ":")
+		if !found {
+			remoteStr = localStr
+		}
+		local, err := parsePort(localStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port pair '%s': %w", field, err)
+		}
+		remote, err := parsePort(remoteStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port pair '%s': %w", field, err)
+		}
+		if usedLocal[local] {
+			return nil, fmt.Errorf("local port %d is used more than once", local)
+		}
+		usedLocal[local] = true
+		pairs = append(pairs, PortPair{Local: local, Remote: remote})
+	}
+	return pairs, nil
+}
+
+// parsePort parses a TCP port number.
+func parsePort(value string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("'%s' is not a port between 1 and 65535", value)
+	}
+	return port, nil
+}
+
+// buildInstancePortSessions prepares one 'aws ssm start-session' call per port
+// pair, forwarding local ports to ports on the instance itself.
+func (a *App) buildInstancePortSessions(sc *Shortcut, region string) ([]sessionCommand, error) {
+	if len(sc.PortPairs) == 0 {
+		return nil, fmt.Errorf("shortcut has no port pairs to forward")
+	}
+	fmt.Println(Cyan("Preparing port forwarding to instance ports..."))
+
+	requested := make([]int, len(sc.PortPairs))
+	for i, p := range sc.PortPairs {
+		requested[i] = p.Local
+	}
+	localPorts, err := allocateLocalPorts(requested, a.settings.PortConflict)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]sessionCommand, len(sc.PortPairs))
+	for i, p := range sc.PortPairs {
+		parameters := fmt.Sprintf("portNumber=%d,localPortNumber=%d", p.Remote, localPorts[i])
+		args := []string{"ssm", "start-session", "--target", sc.InstanceID, "--profile", sc.Profile, "--region", region, "--document-name", "AWS-StartPortForwardingSession", "--parameters", parameters}
+		fmt.Printf(Cyan("Target: %s:%d -> localhost:%d\n"), sc.InstanceID, p.Remote, localPorts[i])
+		sessions[i] = sessionCommand{Args: args, LocalPort: localPorts[i], Remote: fmt.Sprintf("%s:%d", sc.InstanceID, p.Remote)}
+	}
+	return sessions, nil
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/manifoldco/promptui"
+)
+
+// Replacement policies used when several running instances could replace
+// the instance saved in a shortcut.
+const (
+	replacementAsk    = "ask"    // Prompt the user to choose.
+	replacementNewest = "newest" // Use the most recently launched instance.
+	replacementFail   = "fail"   // Report an error.
+)
+
+// resolveShortcutInstance makes sure the shortcut's instance is still running.
+// When it is gone, a running instance from the same Auto Scaling group, or
+// with the same Name tag, takes its place and the shortcut is updated in place.
+func (a *App) resolveShortcutInstance(sc *Shortcut, region string) error {
+	instances, err := fetchRunningInstances(sc.Profile, region)
+	if err != nil {
+		return err
+	}
+	for _, inst := range instances {
+		if inst.ID == sc.InstanceID {
+			return nil
+		}
+	}
+
+	fmt.Printf(Yellow("⚠️  Instance %s (%s) is no longer running, looking for a replacement...\n"), sc.InstanceName, sc.InstanceID)
+	candidates := findReplacementInstances(instances, sc)
+	if len(candidates) == 0 {
+		return newExitError(ExitNotFound, "instance %s (%s) is no longer running and no running instance has the same Auto Scaling group or Name tag", sc.InstanceName, sc.InstanceID)
+	}
+
+	replacement, err := chooseReplacementInstance(candidates, a.settings)
+	if err != nil {
+		return err
+	}
+
+	if err := a.shortcutMgr.replaceInstance(sc, *replacement); err != nil {
+		fmt.Printf(Yellow("Warning: Failed to save shortcut data: %v\n"), err)
+	}
+	fmt.Printf(Green("✅ Using replacement instance %s (%s)\n"), replacement.displayName(), replacement.ID)
+	return nil
+}
+
+// findReplacementInstances returns the running instances that can stand in
+// for the shortcut's instance. Instances from the same Auto Scaling group are
+// preferred; otherwise instances with the same Name tag are used.
+func findReplacementInstances(instances []EC2Instance, sc *Shortcut) []EC2Instance {
+	var candidates []EC2Instance
+	if sc.AutoScalingGroup != "" {
+		for _, inst := range instances {
+			if inst.autoScalingGroup() == sc.AutoScalingGroup {
+				candidates = append(candidates, inst)
+			}
+		}
+		if len(candidates) > 0 {
+			return candidates
+		}
+	}
+
+	// A shortcut without a Name tag stores the instance ID as its name,
+	// which can never match another instance.
+	if sc.InstanceName == "" || sc.InstanceName == sc.InstanceID {
+		return nil
+	}
+	for _, inst := range instances {
+		if inst.Name != nil && *inst.Name == sc.InstanceName {
+			candidates = append(candidates, inst)
+		}
+	}
+	return candidates
+}
+
+// chooseReplacementInstance picks one of several candidates according to the
+// replacement policy of the settings.
+func chooseReplacementInstance(candidates []EC2Instance, settings Settings) (*EC2Instance, error) {
+	// Newest first, for both the 'newest' policy and the prompt.
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].LaunchTime.After(candidates[j].LaunchTime) })
+	if len(candidates) == 1 {
+		return &candidates[0], nil
+	}
+
+	switch settings.ReplacementPolicy {
+	case replacementNewest:
+		return &candidates[0], nil
+	case replacementFail:
+		ids := make([]string, len(candidates))
+		for i, inst := range candidates {
+			ids[i] = inst.ID
+		}
+		return nil, newExitError(ExitAmbiguous, "several instances could replace the saved one: %s", strings.Join(ids, ", "))
+	default:
+		items := make([]string, len(candidates))
+		for i, inst := range candidates {
+			items[i] = fmt.Sprintf("%s (%s, launched %s)", inst.displayName(), inst.ID, inst.LaunchTime.Local().Format("2006-01-02 15:04"))
+		}
+		prompt := promptui.Select{Label: "Select Replacement Instance", Items: items, Size: settings.PageSize}
+		index, _, err := prompt.Run()
+		if err != nil {
+			return nil, fmt.Errorf("instance selection cancelled")
+		}
+		return &candidates[index], nil
+	}
+}
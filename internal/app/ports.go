@@ -0,0 +1,171 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Policies used when the local port of a tunnel is already in use.
+const (
+	portConflictNext = "next" // Use the next free port.
+	portConflictFail = "fail" // Report an error.
+)
+
+// maxPortSearch is how many ports above the requested one are tried.
+const maxPortSearch = 100
+
+// allocateLocalPort returns the local port to use for a tunnel. If the
+// requested port is busy, the process holding it is reported and, depending
+// on the policy, the next free port is used instead.
+func allocateLocalPort(requested int, policy string) (int, error) {
+	return allocatePort(requested, policy, nil)
+}
+
+// allocateLocalPorts allocates local ports for tunnels started together,
+// making sure no two of them end up on the same port.
+func allocateLocalPorts(requested []int, policy string) ([]int, error) {
+	claimed := make(map[int]bool)
+	ports := make([]int, len(requested))
+	for i, port := range requested {
+		allocated, err := allocatePort(port, policy, claimed)
+		if err != nil {
+			return nil, err
+		}
+		claimed[allocated] = true
+		ports[i] = allocated
+	}
+	return ports, nil
+}
+
+// allocatePort implements allocateLocalPort, skipping the ports in claimed.
+func allocatePort(requested int, policy string, claimed map[int]bool) (int, error) {
+	if !claimed[requested] && isPortFree(requested) {
+		return requested, nil
+	}
+
+	holder := "another process"
+	if claimed[requested] {
+		holder = "another tunnel of this session"
+	} else if owner := portOwner(requested); owner != "" {
+		holder = owner
+	}
+	if policy == portConflictFail {
+		return 0, newExitError(ExitFailure, "local port %d is already in use by %s", requested, holder)
+	}
+
+	fmt.Printf(Yellow("⚠️  Local port %d is already in use by %s.\n"), requested, holder)
+	port, err := findFreePort(requested+1, claimed)
+	if err != nil {
+		return 0, err
+	}
+	fmt.Printf(Cyan("ℹ️  Using free local port %d instead.\n"), port)
+	return port, nil
+}
+
+// isPortFree reports whether a TCP port can be bound on the loopback interface.
+func isPortFree(port int) bool {
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return false
+	}
+	listener.Close()
+	return true
+}
+
+// findFreePort returns the first free port starting at start that is not in claimed.
+func findFreePort(start int, claimed map[int]bool) (int, error) {
+	for port := start; port < start+maxPortSearch && port <= 65535; port++ {
+		if !claimed[port] && isPortFree(port) {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("no free local port found between %d and %d", start, start+maxPortSearch-1)
+}
+
+// portOwner returns a description such as "mysqld (PID 1234)" of the
+// process listening on a port. It is best effort and returns an empty
+// string when the owner cannot be determined.
+func portOwner(port int) string {
+	if runtime.GOOS == "windows" {
+		return windowsPortOwner(port)
+	}
+	return lsofPortOwner(port)
+}
+
+// lsofPortOwner uses lsof, available on macOS and most Linux systems.
+func lsofPortOwner(port int) string {
+	output, err := exec.Command("lsof", "-nP", fmt.Sprintf("-iTCP:%d", port), "-sTCP:LISTEN", "-Fpc").Output()
+	if err != nil {
+		return ""
+	}
+
+	// Output fields are prefixed by their type: 'p' for PID, 'c' for command.
+	var pid, command string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		switch line[0] {
+		case 'p':
+			pid = line[1:]
+		case 'c':
+			command = line[1:]
+		}
+		if pid != "" && command != "" {
+			break
+		}
+	}
+	return formatPortOwner(command, pid)
+}
+
+// windowsPortOwner uses netstat and tasklist.
+func windowsPortOwner(port int) string {
+	output, err := exec.Command("netstat", "-ano", "-p", "tcp").Output()
+	if err != nil {
+		return ""
+	}
+
+	var pid string
+	suffix := ":" + strconv.Itoa(port)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Proto, Local Address, Foreign Address, State, PID
+		if len(fields) == 5 && strings.HasSuffix(fields[1], suffix) && fields[3] == "LISTENING" {
+			pid = fields[4]
+			break
+		}
+	}
+	if pid == "" {
+		return ""
+	}
+
+	var command string
+	output, err = exec.Command("tasklist", "/FI", "PID eq "+pid, "/FO", "CSV", "/NH").Output()
+	if err == nil {
+		// "mysqld.exe","1234","Services","0","25,000 K"
+		if name, _, ok := strings.Cut(string(output), ","); ok {
+			command = strings.Trim(name, "\"")
+		}
+	}
+	return formatPortOwner(command, pid)
+}
+
+func formatPortOwner(command, pid string) string {
+	switch {
+	case command != "" && pid != "":
+		return fmt.Sprintf("%s (PID %s)", command, pid)
+	case pid != "":
+		return fmt.Sprintf("PID %s", pid)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !windows
+
+package app
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachProcess makes cmd run in its own session, so it keeps running
+// after the terminal that started it is closed.
+func detachProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// setProcessGroup makes cmd the leader of a new process group, so it can be
+// stopped together with the children it spawns.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessTree stops a process group leader and all its children.
+func killProcessTree(pid int) error {
+	return syscall.Kill(-pid, syscall.SIGTERM)
+}
@@ -0,0 +1,33 @@
+//go:build windows
+
+package app
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// Process creation flags, see
+// https://learn.microsoft.com/windows/win32/procthread/process-creation-flags
+const (
+	createNewProcessGroup = 0x00000200
+	detachedProcess       = 0x00000008
+)
+
+// detachProcess makes cmd run without a console, so it keeps running
+// after the terminal that started it is closed.
+func detachProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: detachedProcess | createNewProcessGroup, HideWindow: true}
+}
+
+// setProcessGroup makes cmd the root of a new process group, so it can be
+// stopped together with the children it spawns.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: createNewProcessGroup, HideWindow: true}
+}
+
+// killProcessTree stops a process and all its children.
+func killProcessTree(pid int) error {
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(pid)).Run()
+}
@@ -0,0 +1,242 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// rdsDiscoveryCache is the on-disk cache of discovered targets, keyed by "profile|region".
+type rdsDiscoveryCache map[string]rdsDiscoveryEntry
+
+type rdsDiscoveryEntry struct {
+	FetchedAt time.Time   `json:"fetched_at"`
+	Targets   []RDSConfig `json:"targets"`
+}
+
+// loadRDSTargets returns the static RDS configurations from rds.json and
+// targets.json merged with the targets discovered for the given profile and
+// region. Static entries win when both describe the same ID or endpoint, and
+// targets.json wins over rds.json, as it does for remote host targets.
+func loadRDSTargets(profile, region string, settings Settings) ([]RDSConfig, error) {
+	static, staticErr := loadStaticRDSConfigs()
+	if staticErr != nil && !errors.Is(staticErr, fs.ErrNotExist) {
+		return nil, staticErr
+	}
+	if !settings.rdsDiscoveryFor(profile) {
+		return static, staticErr
+	}
+
+	discovered, err := getDiscoveredRDSTargets(profile, region, settings.RDSDiscoveryTTL)
+	if err != nil {
+		fmt.Printf(Yellow("Warning: RDS discovery failed, using rds.json only: %v\n"), err)
+	}
+	if len(discovered) == 0 {
+		return static, staticErr
+	}
+	return mergeRDSTargets(static, discovered), nil
+}
+
+// loadStaticRDSConfigs returns the RDS targets of targets.json followed by
+// the entries of rds.json they do not redefine. The error of a missing
+// rds.json is only returned when targets.json has no RDS targets either.
+func loadStaticRDSConfigs() ([]RDSConfig, error) {
+	configs, err := loadRDSConfig()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	targets, targetsErr := loadTargetsConfig()
+	if targetsErr != nil {
+		return nil, targetsErr
+	}
+	fromTargets := targetsRDSConfigs(targets)
+	if len(fromTargets) == 0 {
+		return configs, err
+	}
+	for _, c := range configs {
+		if !slices.ContainsFunc(fromTargets, func(t RDSConfig) bool { return t.ID() == c.ID() }) {
+			fromTargets = append(fromTargets, c)
+		}
+	}
+	return fromTargets, nil
+}
+
+// mergeRDSTargets appends the discovered targets that are not already
+// configured statically.
+func mergeRDSTargets(static, discovered []RDSConfig) []RDSConfig {
+	seen := make(map[string]bool)
+	for _, conf := range static {
+		seen[conf.ID()] = true
+		seen[strings.ToLower(conf.Endpoint)] = true
+	}
+
+	merged := append([]RDSConfig{}, static...)
+	for _, conf := range discovered {
+		if seen[conf.ID()] || seen[strings.ToLower(conf.Endpoint)] {
+			continue
+		}
+		conf.Discovered = true
+		merged = append(merged, conf)
+	}
+	return merged
+}
+
+// getDiscoveredRDSTargets returns the discovered targets from the cache, or
+// queries AWS when the cached entry is missing or older than ttl.
+func getDiscoveredRDSTargets(profile, region string, ttl time.Duration) ([]RDSConfig, error) {
+	cache, err := loadRDSDiscoveryCache()
+	if err != nil {
+		fmt.Printf(Yellow("Warning: Failed to load RDS discovery cache: %v\n"), err)
+		cache = make(rdsDiscoveryCache)
+	}
+
+	cacheKey := profile + "|" + region
+	if entry, ok := cache[cacheKey]; ok && time.Since(entry.FetchedAt) < ttl {
+		return entry.Targets, nil
+	}
+
+	targets, err := discoverRDSTargets(profile, region)
+	if err != nil {
+		return nil, err
+	}
+
+	cache[cacheKey] = rdsDiscoveryEntry{FetchedAt: time.Now(), Targets: targets}
+	if err := saveRDSDiscoveryCache(cache); err != nil {
+		fmt.Printf(Yellow("Warning: Failed to save RDS discovery cache: %v\n"), err)
+	}
+	return targets, nil
+}
+
+// discoverRDSTargets lists the RDS/Aurora clusters and standalone instances
+// of a profile and region. Cluster writer and reader endpoints map to the
+// "write" and "read" types; standalone instances are "write" unless they
+// are read replicas.
+func discoverRDSTargets(profile, region string) ([]RDSConfig, error) {
+	fmt.Println(Cyan("ℹ️  Discovering RDS endpoints..."))
+
+	var targets []RDSConfig
+	err := callAWS(profile, region, func(ctx context.Context, cfg aws.Config) error {
+		targets = nil
+		client := rds.NewFromConfig(cfg)
+
+		clusters := rds.NewDescribeDBClustersPaginator(client, &rds.DescribeDBClustersInput{})
+		for clusters.HasMorePages() {
+			page, err := clusters.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to describe DB clusters: %w", err)
+			}
+			for _, c := range page.DBClusters {
+				id, port := aws.ToString(c.DBClusterIdentifier), int(aws.ToInt32(c.Port))
+				env := discoveredEnv(id, c.TagList, profile)
+				if c.Endpoint != nil {
+					targets = append(targets, newDiscoveredTarget(id, env, "write", *c.Endpoint, port))
+				}
+				if c.ReaderEndpoint != nil {
+					targets = append(targets, newDiscoveredTarget(id, env, "read", *c.ReaderEndpoint, port))
+				}
+			}
+		}
+
+		instances := rds.NewDescribeDBInstancesPaginator(client, &rds.DescribeDBInstancesInput{})
+		for instances.HasMorePages() {
+			page, err := instances.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to describe DB instances: %w", err)
+			}
+			for _, inst := range page.DBInstances {
+				if inst.DBClusterIdentifier != nil || inst.Endpoint == nil || inst.Endpoint.Address == nil {
+					continue // Part of a cluster, or still being created.
+				}
+				id := aws.ToString(inst.DBInstanceIdentifier)
+				connType := "write"
+				if aws.ToString(inst.ReadReplicaSourceDBInstanceIdentifier) != "" {
+					connType = "read"
+				}
+				targets = append(targets, newDiscoveredTarget(id, discoveredEnv(id, inst.TagList, profile), connType, *inst.Endpoint.Address, int(aws.ToInt32(inst.Endpoint.Port))))
+			}
+		}
+		return nil
+	})
+	return targets, err
+}
+
+// newDiscoveredTarget builds an RDS configuration for a discovered endpoint.
+// The local port defaults to the database port plus one, so a database
+// server running locally on its default port is not shadowed.
+func newDiscoveredTarget(key, env, connType, endpoint string, port int) RDSConfig {
+	return RDSConfig{
+		Key:       key,
+		Env:       env,
+		Type:      connType,
+		Endpoint:  endpoint,
+		Port:      port,
+		LocalPort: port + 1,
+	}
+}
+
+// discoveredEnv derives the environment of a discovered database from its
+// "env" or "environment" tag, then from the identifier prefix before the
+// first '-', and falls back to the profile name.
+func discoveredEnv(id string, tags []rdstypes.Tag, profile string) string {
+	for _, tag := range tags {
+		switch strings.ToLower(aws.ToString(tag.Key)) {
+		case "env", "environment":
+			if value := aws.ToString(tag.Value); value != "" {
+				return strings.ToLower(value)
+			}
+		}
+	}
+	if prefix, _, ok := strings.Cut(id, "-"); ok && prefix != "" {
+		return prefix
+	}
+	return profile
+}
+
+func loadRDSDiscoveryCache() (rdsDiscoveryCache, error) {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return nil, err
+	}
+	filePath := filepath.Join(dataDir, "rds_discovery.json")
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make(rdsDiscoveryCache), nil
+		}
+		return nil, err
+	}
+	var cache rdsDiscoveryCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("file %s is corrupted: %w", filePath, err)
+	}
+	return cache, nil
+}
+
+func saveRDSDiscoveryCache(cache rdsDiscoveryCache) error {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return err
+	}
+
+	filePath := filepath.Join(dataDir, "rds_discovery.json")
+	bytes, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, bytes, 0644)
+}
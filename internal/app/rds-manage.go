@@ -0,0 +1,427 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/manifoldco/promptui"
+)
+
+// rdsConfigPath returns the path of rds.json in the configuration directory.
+func rdsConfigPath() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "rds.json"), nil
+}
+
+// rdsFile is rds.json as edited by the rds subcommands. Entries are kept as
+// they are written, so that the ones a command does not change stay as they
+// are, even when they have unknown fields or values of the wrong type.
+type rdsFile struct {
+	path    string
+	entries []json.RawMessage
+	configs []RDSConfig // The entries that could be read.
+	targets []RDSConfig // The RDS targets of targets.json, which are not edited.
+}
+
+// loadRDSFile reads rds.json for the rds subcommands. A missing file has no
+// entries; a file that is not a valid list is not edited.
+func loadRDSFile() (*rdsFile, error) {
+	path, err := rdsConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	targets, err := loadTargetsConfig()
+	if err != nil {
+		return nil, err
+	}
+	f := &rdsFile{path: path, targets: targetsRDSConfigs(targets)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if f.configs, err = loadRDSConfig(); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &f.entries); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// index returns the position in rds.json of the entry with the given ID, or
+// -1. Only the entries that could be read count, so an entry with an error
+// that repeats the ID of a valid one is never the one changed.
+func (f *rdsFile) index(id string) int {
+	for _, c := range f.configs {
+		if c.ID() == id {
+			return c.entry
+		}
+	}
+	return -1
+}
+
+// remove deletes the entries with the given IDs.
+func (f *rdsFile) remove(ids []string) {
+	var indexes []int
+	for _, id := range ids {
+		if index := f.index(id); index >= 0 {
+			indexes = append(indexes, index)
+		}
+	}
+	// Delete from the end so the positions of the others stay valid.
+	slices.Sort(indexes)
+	for _, index := range slices.Backward(indexes) {
+		f.entries = slices.Delete(f.entries, index, index+1)
+	}
+}
+
+// resolve finds the entry of rds.json referenced by ref, like
+// resolveRDSConfig. A reference to an RDS target of targets.json is reported
+// as such, since the rds subcommands only change rds.json.
+func (f *rdsFile) resolve(ref string) (*RDSConfig, error) {
+	c, err := resolveRDSConfig(f.configs, ref)
+	if ExitCode(err) == ExitNotFound {
+		if t, targetsErr := resolveRDSConfig(f.targets, ref); targetsErr == nil {
+			return nil, newExitError(ExitUsage, "RDS target %s is defined in targets.json; change it in that file", t.ID())
+		}
+	}
+	return c, err
+}
+
+// exists reports whether an entry of rds.json other than the one at skip, or
+// an RDS target of targets.json, has the given ID.
+func (f *rdsFile) exists(id string, skip int) (string, bool) {
+	if found := f.index(id); found >= 0 && found != skip {
+		return "rds.json", true
+	}
+	if slices.ContainsFunc(f.targets, func(c RDSConfig) bool { return c.ID() == id }) {
+		return "targets.json", true
+	}
+	return "", false
+}
+
+// save writes rds.json atomically, keeping the previous version in
+// rds.json.bak.
+func (f *rdsFile) save() error {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i, raw := range f.entries {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n  ")
+		buf.Write(raw)
+	}
+	if len(f.entries) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("]\n")
+	data := buf.Bytes()
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if previous, err := os.ReadFile(f.path); err == nil {
+		if err := writeFileAtomic(f.path+".bak", previous, 0644); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", f.path, err)
+		}
+	}
+	if err := writeFileAtomic(f.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.path, err)
+	}
+	return nil
+}
+
+// warnSharedLocalPort warns when another entry than the one with the given
+// ID, in rds.json or targets.json, uses the same local port, since their
+// tunnels cannot run at once.
+func (f *rdsFile) warnSharedLocalPort(entry RDSConfig, id string) {
+	for _, c := range slices.Concat(f.configs, f.targets) {
+		if c.LocalPort == entry.LocalPort && c.ID() != id {
+			fmt.Fprintf(os.Stderr, Yellow("Warning: local port %d is also used by %s; use 'asmago rds edit' to give one of them another port\n"), entry.LocalPort, c.ID())
+			return
+		}
+	}
+}
+
+// encodeRDSEntry encodes an entry for rds.json. The fields of previous that
+// RDSConfig does not know are kept, so that editing an entry preserves them.
+func encodeRDSEntry(c RDSConfig, previous json.RawMessage) (json.RawMessage, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if previous != nil && json.Unmarshal(previous, &fields) == nil {
+		for _, name := range slices.Sorted(maps.Keys(fields)) {
+			if slices.ContainsFunc(rdsFields, func(f string) bool { return strings.EqualFold(f, name) }) {
+				continue
+			}
+			key, _ := json.Marshal(name)
+			data = fmt.Appendf(data[:len(data)-1], ",%s:%s}", key, fields[name])
+		}
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "  ", "  "); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ListRDSTargets displays the entries of rds.json and the RDS targets of
+// targets.json with their usage counts.
+func (a *App) ListRDSTargets() error {
+	f, err := loadRDSFile()
+	if err != nil {
+		return err
+	}
+	configs := slices.Concat(f.configs, f.targets)
+	if len(configs) == 0 {
+		fmt.Println(Yellow("No RDS targets found; add one with 'asmago rds add'."))
+		return nil
+	}
+	usageData, err := loadRdsUsageData()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tENDPOINT\tLOCAL PORT\tUSES\tFILE")
+	for i, c := range configs {
+		file := "rds.json"
+		if i >= len(f.configs) {
+			file = "targets.json"
+		} else if slices.ContainsFunc(f.targets, func(t RDSConfig) bool { return t.ID() == c.ID() }) {
+			file = "rds.json (unused, redefined in targets.json)"
+		}
+		fmt.Fprintf(w, "%s\t%s:%d\t%d\t%d\t%s\n", c.ID(), c.Endpoint, c.Port, c.LocalPort, usageData[c.ID()].Count, file)
+	}
+	return w.Flush()
+}
+
+// AddRDSTarget prompts for a new entry and appends it to rds.json.
+func (a *App) AddRDSTarget() error {
+	f, err := loadRDSFile()
+	if err != nil {
+		return err
+	}
+	entry, err := promptRDSConfig(RDSConfig{Type: "read", Port: 5432, LocalPort: nextLocalPort(slices.Concat(f.configs, f.targets))})
+	if err != nil {
+		fmt.Println("Process cancelled.")
+		return nil
+	}
+	if file, ok := f.exists(entry.ID(), -1); ok {
+		return newExitError(ExitUsage, "an RDS target %s already exists in %s; change it there", entry.ID(), file)
+	}
+
+	raw, err := encodeRDSEntry(entry, nil)
+	if err != nil {
+		return err
+	}
+	f.entries = append(f.entries, raw)
+	if err := f.save(); err != nil {
+		return err
+	}
+	fmt.Printf(Green("✅ Added RDS target: %s\n"), entry.ID())
+	f.warnSharedLocalPort(entry, entry.ID())
+	return nil
+}
+
+// EditRDSTarget prompts for new values of an entry of rds.json, referenced by
+// its key|env|type ID or a unique key. When the ID changes, usage data and
+// shortcuts follow the entry.
+func (a *App) EditRDSTarget(ref string) error {
+	f, err := loadRDSFile()
+	if err != nil {
+		return err
+	}
+	current, err := f.resolve(ref)
+	if err != nil {
+		return err
+	}
+	oldID := current.ID()
+	index := f.index(oldID)
+
+	entry, err := promptRDSConfig(*current)
+	if err != nil {
+		fmt.Println("Process cancelled.")
+		return nil
+	}
+	newID := entry.ID()
+	if file, ok := f.exists(newID, index); ok {
+		return newExitError(ExitUsage, "an RDS target %s already exists in %s", newID, file)
+	}
+
+	if f.entries[index], err = encodeRDSEntry(entry, f.entries[index]); err != nil {
+		return err
+	}
+	if err := f.save(); err != nil {
+		return err
+	}
+	if newID != oldID {
+		if err := renameRdsUsage(oldID, newID); err != nil {
+			fmt.Printf(Yellow("Warning: Failed to save RDS usage data: %v\n"), err)
+		}
+		moved, err := a.shortcutMgr.renameRDSTarget(oldID, newID)
+		if err != nil {
+			return fmt.Errorf("failed to save shortcut data: %w", err)
+		}
+		if moved > 0 {
+			fmt.Printf(Cyan("ℹ️  Updated %d shortcut(s) to use %s\n"), moved, newID)
+		}
+	}
+	fmt.Printf(Green("✅ Updated RDS target: %s\n"), newID)
+	f.warnSharedLocalPort(entry, oldID)
+	return nil
+}
+
+// RemoveRDSTargets deletes entries of rds.json, referenced by their
+// key|env|type ID or a unique key.
+func (a *App) RemoveRDSTargets(refs []string) error {
+	f, err := loadRDSFile()
+	if err != nil {
+		return err
+	}
+	// Resolve every reference first so nothing is removed if one is wrong.
+	var ids []string
+	for _, ref := range refs {
+		c, err := f.resolve(ref)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(ids, c.ID()) {
+			ids = append(ids, c.ID())
+		}
+	}
+
+	f.remove(ids)
+	if err := f.save(); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		fmt.Printf(Green("✅ Removed RDS target: %s\n"), id)
+		if n := a.shortcutMgr.countRDSTarget(id); n > 0 {
+			fmt.Printf(Yellow("Warning: %d shortcut(s) still use %s; remove them with 'asmago shortcuts rm'\n"), n, id)
+		}
+	}
+	return nil
+}
+
+// RDSTargetIDs returns the IDs of the entries of rds.json, for shell completion.
+func RDSTargetIDs() []string {
+	f, err := loadRDSFile()
+	if err != nil {
+		return nil
+	}
+	ids := make([]string, len(f.configs))
+	for i, c := range f.configs {
+		ids[i] = c.ID()
+	}
+	return ids
+}
+
+// nextLocalPort returns the local port following the highest one in use, or
+// 15432 for the first entry.
+func nextLocalPort(configs []RDSConfig) int {
+	highest := 15431
+	for _, c := range configs {
+		highest = max(highest, c.LocalPort)
+	}
+	return min(highest+1, 65535)
+}
+
+// promptRDSConfig asks for the fields of an entry, starting from defaults.
+// It returns the error of the first prompt that is cancelled.
+func promptRDSConfig(defaults RDSConfig) (RDSConfig, error) {
+	text := func(label, value string) (string, error) {
+		prompt := promptui.Prompt{
+			Label:     label,
+			Default:   value,
+			AllowEdit: true,
+			Validate: func(input string) error {
+				if strings.TrimSpace(input) == "" {
+					return fmt.Errorf("%s cannot be empty", strings.ToLower(label))
+				}
+				if strings.Contains(input, "|") {
+					return fmt.Errorf("%s cannot contain '|'", strings.ToLower(label))
+				}
+				return nil
+			},
+		}
+		result, err := prompt.Run()
+		return strings.TrimSpace(result), err
+	}
+	port := func(label string, value int) (int, error) {
+		prompt := promptui.Prompt{
+			Label:     label,
+			Default:   strconv.Itoa(value),
+			AllowEdit: true,
+			Validate: func(input string) error {
+				if n, err := strconv.Atoi(strings.TrimSpace(input)); err != nil || n < 1 || n > 65535 {
+					return fmt.Errorf("enter a port between 1 and 65535")
+				}
+				return nil
+			},
+		}
+		result, err := prompt.Run()
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(strings.TrimSpace(result))
+	}
+
+	var c RDSConfig
+	var err error
+	if c.Key, err = text("Key", defaults.Key); err != nil {
+		return c, err
+	}
+	if c.Env, err = text("Env", defaults.Env); err != nil {
+		return c, err
+	}
+	types := []string{"read", "write"}
+	typePrompt := promptui.Select{Label: "Type", Items: types, CursorPos: max(slices.Index(types, defaults.Type), 0)}
+	if _, c.Type, err = typePrompt.Run(); err != nil {
+		return c, err
+	}
+	if c.Endpoint, err = text("Endpoint", defaults.Endpoint); err != nil {
+		return c, err
+	}
+	if c.Port, err = port("Port", defaults.Port); err != nil {
+		return c, err
+	}
+	if c.LocalPort, err = port("Local port", defaults.LocalPort); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// renameRdsUsage moves the usage record of an RDS target to its new ID.
+func renameRdsUsage(oldID, newID string) error {
+	usageData, err := loadRdsUsageData()
+	if err != nil {
+		return err
+	}
+	record, ok := usageData[oldID]
+	if !ok {
+		return nil
+	}
+	delete(usageData, oldID)
+	usageData[newID] = record
+	return saveRdsUsageData(usageData)
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRDSFileSkipsEntriesWithErrors(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	path := filepath.Join(configHome, "asmago", "rds.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	data := strings.Join([]string{
+		`[`,
+		`  {"key": "a", "env": "dev", "type": "read", "port": 5432, "local_port": 15432},`,
+		`  {"key": "a", "env": "dev", "type": "read", "endpoint": "a.x", "port": 5432, "local_port": 15433},`,
+		`  {"key": "b", "env": "dev", "type": "read", "endpoint": "b.x", "port": 5432, "local_port": 15434}`,
+		`]`,
+	}, "\n")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := loadRDSFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Entry 1 has no endpoint, so a|dev|read is entry 2.
+	for id, want := range map[string]int{"a|dev|read": 1, "b|dev|read": 2, "c|dev|read": -1} {
+		if got := f.index(id); got != want {
+			t.Errorf("index(%q) = %d, want %d", id, got, want)
+		}
+	}
+
+	f.remove([]string{"b|dev|read", "a|dev|read"})
+	if err := f.save(); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(saved), "a.x") || strings.Contains(string(saved), "b.x") {
+		t.Errorf("removed entries are still in rds.json:\n%s", saved)
+	}
+	if !strings.Contains(string(saved), `"local_port": 15432`) {
+		t.Errorf("the entry with an error was not kept:\n%s", saved)
+	}
+}
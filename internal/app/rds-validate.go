@@ -0,0 +1,470 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/lithammer/fuzzysearch/fuzzy"
+)
+
+// rdsFields lists the fields of an rds.json entry.
+var rdsFields = []string{"key", "env", "type", "endpoint", "port", "local_port"}
+
+// configProblem is a mistake found in a configuration file, located as
+// precisely as possible, with a suggestion to fix it.
+type configProblem struct {
+	Path       string
+	Line, Col  int    // 1-based; zero when the problem has no position in the file.
+	Entry      int    // 1-based index of the entry, zero when not about an entry.
+	Label      string // ID of the entry, if it has one.
+	Warning    bool   // The file can still be used as it is.
+	Message    string
+	Suggestion string
+}
+
+func (p configProblem) Error() string {
+	var b strings.Builder
+	b.WriteString(p.Path)
+	if p.Line > 0 {
+		fmt.Fprintf(&b, ":%d:%d", p.Line, p.Col)
+	}
+	if p.Entry > 0 {
+		fmt.Fprintf(&b, ": entry %d", p.Entry)
+		if p.Label != "" {
+			fmt.Fprintf(&b, " (%s)", p.Label)
+		}
+	}
+	b.WriteString(": " + p.Message)
+	if p.Suggestion != "" {
+		b.WriteString("\n    fix: " + p.Suggestion)
+	}
+	return b.String()
+}
+
+// rdsEntryPosition records where an entry of rds.json and its fields start.
+type rdsEntryPosition struct {
+	offset int
+	fields map[string]int // Offset of each field name, as written.
+	order  []string       // Field names in the order they are written.
+	raw    json.RawMessage
+}
+
+// lineCol returns the position of a field of the entry, or of the entry
+// itself when the field is not written.
+func (pos rdsEntryPosition) lineCol(data []byte, field string) (int, int) {
+	if offset, ok := pos.fields[field]; ok {
+		return lineCol(data, offset)
+	}
+	return lineCol(data, pos.offset)
+}
+
+// parseRDSConfig parses and checks the content of rds.json. A file that is
+// not valid JSON, or not a list, is returned as an error. Other problems are
+// returned alongside the entries; entries with a problem that is not a
+// warning are left out, as are the later entries repeating an ID.
+func parseRDSConfig(data []byte, path string) ([]RDSConfig, []configProblem, error) {
+	var syntaxCheck any
+	if err := json.Unmarshal(data, &syntaxCheck); err != nil {
+		return nil, nil, jsonSyntaxProblem(data, path, err)
+	}
+	if _, ok := syntaxCheck.([]any); !ok {
+		line, col := lineCol(data, skipJSONSpace(data, 0))
+		return nil, nil, configProblem{Path: path, Line: line, Col: col, Message: "expected a list of entries", Suggestion: "wrap the entries in [ and ], e.g. [{\"key\": \"billing\", ...}]"}
+	}
+
+	positions := scanRDSEntries(data)
+	var configs []RDSConfig
+	var problems []configProblem
+	entries := make([]int, 0, len(positions)) // Entry index of each config.
+	for i, pos := range positions {
+		failed := false
+		report := func(field string, warning bool, label, message, suggestion string) {
+			line, col := pos.lineCol(data, field)
+			problems = append(problems, configProblem{Path: path, Line: line, Col: col, Entry: i + 1, Label: label, Warning: warning, Message: message, Suggestion: suggestion})
+			failed = failed || !warning
+		}
+
+		// Unmarshal fills the other fields when one has the wrong type, so the
+		// entry is still checked, but left out of the result.
+		var c RDSConfig
+		var badField string
+		if err := json.Unmarshal(pos.raw, &c); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if !errors.As(err, &typeErr) || typeErr.Field == "" {
+				report("", false, "", "expected an object with "+strings.Join(rdsFields, ", "), "write the entry as {\"key\": ..., \"env\": ..., ...}")
+				continue
+			}
+			badField = typeErr.Field
+			report(badField, false, c.ID(), fmt.Sprintf("%q must be a %s, not a %s", badField, jsonTypeName(typeErr.Type.Kind().String()), typeErr.Value), typeSuggestion(badField))
+		}
+		label := c.ID()
+
+		for _, field := range pos.order {
+			if slices.ContainsFunc(rdsFields, func(f string) bool { return strings.EqualFold(f, field) }) {
+				continue
+			}
+			suggestion := "remove it; entries have " + strings.Join(rdsFields, ", ")
+			if closest := closestMatch(field, rdsFields, 3); closest != "" {
+				suggestion = fmt.Sprintf("did you mean %q?", closest)
+			}
+			report(field, true, label, fmt.Sprintf("unknown field %q is ignored", field), suggestion)
+		}
+
+		for _, required := range []struct{ field, value, example string }{
+			{"key", c.Key, `"key": "billing"`},
+			{"env", c.Env, `"env": "dev"`},
+			{"endpoint", c.Endpoint, `"endpoint": "billing.cluster-ro-abc123.ap-southeast-1.rds.amazonaws.com"`},
+		} {
+			if strings.TrimSpace(required.value) == "" {
+				if required.field == badField {
+					continue
+				}
+				report(required.field, false, label, fmt.Sprintf("%q is missing or empty", required.field), "set it, e.g. "+required.example)
+			}
+		}
+
+		if c.Type != "read" && c.Type != "write" && badField != "type" {
+			suggestion := `use "read" or "write"`
+			if lower := strings.ToLower(c.Type); lower == "read" || lower == "write" {
+				suggestion = fmt.Sprintf("write it in lower case: %q", lower)
+			} else if closest := closestMatch(lower, []string{"read", "write"}, 3); closest != "" {
+				suggestion = fmt.Sprintf("did you mean %q?", closest)
+			}
+			message := fmt.Sprintf("\"type\" is %q", c.Type)
+			if c.Type == "" {
+				message = "\"type\" is missing or empty"
+			}
+			report("type", false, label, message, suggestion)
+		}
+
+		if (c.Port < 1 || c.Port > 65535) && badField != "port" {
+			report("port", false, label, fmt.Sprintf("\"port\" %d is outside 1-65535", c.Port), "use the database port, e.g. 5432 for PostgreSQL or 3306 for MySQL")
+		}
+		if (c.LocalPort < 1 || c.LocalPort > 65535) && badField != "local_port" {
+			report("local_port", false, label, fmt.Sprintf("\"local_port\" %d is outside 1-65535", c.LocalPort), fmt.Sprintf("use a free port on your machine, e.g. %d", suggestLocalPort(configs, c.Port)))
+		}
+
+		if !failed {
+			c.entry = i
+			configs = append(configs, c)
+			entries = append(entries, i)
+		}
+	}
+
+	// Checks across entries.
+	var unique []RDSConfig
+	for j, c := range configs {
+		pos := positions[entries[j]]
+		if k := slices.IndexFunc(configs[:j], func(other RDSConfig) bool { return other.ID() == c.ID() }); k >= 0 {
+			line, col := pos.lineCol(data, "")
+			problems = append(problems, configProblem{Path: path, Line: line, Col: col, Entry: entries[j] + 1, Label: c.ID(), Message: fmt.Sprintf("duplicates the key, env and type of entry %d", entries[k]+1), Suggestion: "change the key, env or type of one of them, or remove the duplicate"})
+			continue
+		}
+		unique = append(unique, c)
+		if k := slices.IndexFunc(configs[:j], func(other RDSConfig) bool { return other.LocalPort == c.LocalPort }); k >= 0 && c.LocalPort != 0 {
+			line, col := pos.lineCol(data, "local_port")
+			problems = append(problems, configProblem{Path: path, Line: line, Col: col, Entry: entries[j] + 1, Label: c.ID(), Warning: true, Message: fmt.Sprintf("\"local_port\" %d is also used by entry %d (%s)", c.LocalPort, entries[k]+1, configs[k].ID()), Suggestion: fmt.Sprintf("give one of them another local port, e.g. %d, to run both tunnels at once", suggestLocalPort(configs, c.LocalPort))})
+		}
+	}
+	slices.SortStableFunc(problems, func(a, b configProblem) int {
+		if a.Line != b.Line {
+			return a.Line - b.Line
+		}
+		return a.Col - b.Col
+	})
+	return unique, problems, nil
+}
+
+// scanRDSEntries finds where each entry of a valid rds.json and its fields start.
+func scanRDSEntries(data []byte) []rdsEntryPosition {
+	var positions []rdsEntryPosition
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return nil
+	}
+	for dec.More() {
+		start := skipJSONSpace(data, int(dec.InputOffset()))
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return positions
+		}
+		pos := rdsEntryPosition{offset: start, fields: make(map[string]int), raw: raw}
+
+		sub := json.NewDecoder(bytes.NewReader(raw))
+		if tok, err := sub.Token(); err == nil && tok == json.Delim('{') {
+			for sub.More() {
+				keyStart := skipJSONSpace(raw, int(sub.InputOffset()))
+				tok, err := sub.Token()
+				if err != nil {
+					break
+				}
+				key, _ := tok.(string)
+				pos.fields[key] = start + keyStart
+				pos.order = append(pos.order, key)
+				var value json.RawMessage
+				if err := sub.Decode(&value); err != nil {
+					break
+				}
+			}
+		}
+		positions = append(positions, pos)
+	}
+	return positions
+}
+
+// jsonSyntaxProblem locates a JSON syntax error and suggests a fix.
+func jsonSyntaxProblem(data []byte, path string, err error) configProblem {
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return configProblem{Path: path, Message: err.Error()}
+	}
+	offset := max(int(syntaxErr.Offset)-1, 0)
+	line, col := lineCol(data, offset)
+	p := configProblem{Path: path, Line: line, Col: col, Message: syntaxErr.Error()}
+
+	previous := lastNonSpace(data, offset)
+	msg := syntaxErr.Error()
+	switch {
+	case strings.Contains(msg, "unexpected end of JSON input"):
+		p.Suggestion = "close every [ and { that is opened"
+	case previous == ',' && (strings.Contains(msg, "looking for beginning of object key string") || strings.Contains(msg, "looking for beginning of value")):
+		p.Suggestion = "remove the trailing comma before this character"
+	case strings.Contains(msg, "after object key:value pair") || strings.Contains(msg, "after array element"):
+		p.Suggestion = "add a comma before this character"
+	case offset < len(data) && data[offset] == '\'':
+		p.Suggestion = "use double quotes for strings and field names"
+	case strings.Contains(msg, "looking for beginning of object key string"):
+		p.Suggestion = "put field names in double quotes, e.g. \"key\""
+	case strings.Contains(msg, "after object key"):
+		p.Suggestion = "add a colon between the field name and its value"
+	case strings.Contains(msg, "looking for beginning of value"):
+		p.Suggestion = "put strings in double quotes; numbers and true/false stay bare"
+	}
+	return p
+}
+
+// typeSuggestion explains the type expected for a field of rds.json.
+func typeSuggestion(field string) string {
+	switch field {
+	case "port", "local_port":
+		return fmt.Sprintf("write the number without quotes, e.g. \"%s\": 5432", field)
+	}
+	return fmt.Sprintf("put the value in double quotes, e.g. \"%s\": \"dev\"", field)
+}
+
+// jsonTypeName names a Go kind the way JSON users know it.
+func jsonTypeName(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "number"
+	case kind == "struct", kind == "map":
+		return "object"
+	case kind == "slice":
+		return "list"
+	}
+	return kind
+}
+
+// suggestLocalPort returns a local port above base that no entry uses.
+func suggestLocalPort(configs []RDSConfig, base int) int {
+	used := make(map[int]bool)
+	for _, c := range configs {
+		used[c.LocalPort] = true
+	}
+	port := base + 1
+	if port < 1024 || port > 65535 {
+		port = 15432
+	}
+	for used[port] && port < 65535 {
+		port++
+	}
+	return port
+}
+
+// closestMatch returns the candidate nearest to s if it is at most maxDistance
+// edits away, or an empty string.
+func closestMatch(s string, candidates []string, maxDistance int) string {
+	best, bestDistance := "", maxDistance+1
+	for _, c := range candidates {
+		if d := fuzzy.LevenshteinDistance(strings.ToLower(s), strings.ToLower(c)); d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+	return best
+}
+
+// lineCol converts a byte offset of data to a 1-based line and column.
+func lineCol(data []byte, offset int) (int, int) {
+	offset = min(offset, len(data))
+	line := 1 + bytes.Count(data[:offset], []byte("\n"))
+	col := offset - bytes.LastIndexByte(data[:offset], '\n')
+	return line, col
+}
+
+// skipJSONSpace returns the offset of the first byte at or after offset that
+// is neither white space nor a comma.
+func skipJSONSpace(data []byte, offset int) int {
+	for offset < len(data) && strings.IndexByte(" \t\r\n,", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// lastNonSpace returns the last byte before offset that is not white space.
+func lastNonSpace(data []byte, offset int) byte {
+	for i := min(offset, len(data)) - 1; i >= 0; i-- {
+		if strings.IndexByte(" \t\r\n", data[i]) < 0 {
+			return data[i]
+		}
+	}
+	return 0
+}
+
+// staleShortcutProblems reports RDS shortcuts whose RDS_ID matches neither
+// one of configs, the RDS targets of rds.json and targets.json, nor a cached
+// discovered target.
+func staleShortcutProblems(configs []RDSConfig) ([]configProblem, error) {
+	shortcuts, err := loadShortcuts()
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	var ids []string
+	for _, c := range configs {
+		known[c.ID()] = true
+		ids = append(ids, c.ID())
+	}
+	if cache, err := loadRDSDiscoveryCache(); err == nil {
+		for _, entry := range cache {
+			for _, c := range entry.Targets {
+				known[c.ID()] = true
+			}
+		}
+	}
+
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dataDir, "shortcuts.json")
+	var problems []configProblem
+	for _, key := range slices.Sorted(maps.Keys(shortcuts)) {
+		sc := shortcuts[key]
+		if sc.Action != actionRDS || known[sc.RDS_ID] {
+			continue
+		}
+		name := sc.Name
+		if name == "" {
+			name = sc.DisplayString
+		}
+		suggestion := "pick the target again in the manual flow, then remove the old shortcut with 'asmago shortcuts rm'"
+		if closest := closestMatch(sc.RDS_ID, ids, 6); closest != "" {
+			suggestion = fmt.Sprintf("the entry may have been renamed to %s; %s", closest, suggestion)
+		}
+		problems = append(problems, configProblem{Path: path, Warning: true, Message: fmt.Sprintf("shortcut '%s' uses RDS target %s, which no longer exists", name, sc.RDS_ID), Suggestion: suggestion})
+	}
+	return problems, nil
+}
+
+// ValidateConfig checks config.yaml, rds.json, targets.json and the RDS
+// targets of saved shortcuts, and prints every problem found. It fails if any is an error.
+func ValidateConfig() error {
+	var errorCount, warningCount int
+	printProblem := func(err error, warning bool) {
+		if warning {
+			warningCount++
+			fmt.Println(Yellow("⚠️  " + err.Error()))
+		} else {
+			errorCount++
+			fmt.Println(Red("❌ " + err.Error()))
+		}
+	}
+
+	configPath, err := ConfigFilePath()
+	if err != nil {
+		return err
+	}
+	s := defaultSettings()
+	for _, problem := range applyConfigFile(&s, configPath) {
+		printProblem(problem, false)
+	}
+
+	rdsPath, err := rdsConfigPath()
+	if err != nil {
+		return err
+	}
+	var configs []RDSConfig
+	rdsValid := true
+	data, err := os.ReadFile(rdsPath)
+	switch {
+	case os.IsNotExist(err):
+		fmt.Printf(Cyan("ℹ️  %s does not exist; only discovered RDS targets are available\n"), rdsPath)
+	case err != nil:
+		return fmt.Errorf("failed to read %s: %w", rdsPath, err)
+	default:
+		var problems []configProblem
+		configs, problems, err = parseRDSConfig(data, rdsPath)
+		if err != nil {
+			printProblem(err, false)
+			rdsValid = false
+		}
+		for _, problem := range problems {
+			printProblem(problem, problem.Warning)
+		}
+	}
+
+	targetsPath, err := targetsConfigPath()
+	if err != nil {
+		return err
+	}
+	data, err = os.ReadFile(targetsPath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("failed to read %s: %w", targetsPath, err)
+	default:
+		targets, problems, err := parseTargetsConfig(data, targetsPath)
+		if err != nil {
+			printProblem(err, false)
+			rdsValid = false
+		}
+		for _, problem := range problems {
+			printProblem(problem, problem.Warning)
+		}
+		fromTargets := targetsRDSConfigs(targets)
+		for _, c := range fromTargets {
+			if slices.ContainsFunc(configs, func(other RDSConfig) bool { return other.ID() == c.ID() }) {
+				printProblem(configProblem{Path: targetsPath, Warning: true, Message: fmt.Sprintf("RDS target %s is also defined in rds.json; the entry of targets.json is used", c.ID()), Suggestion: "remove it from one of the files"}, true)
+			}
+		}
+		configs = append(configs, fromTargets...)
+	}
+
+	// Shortcuts can only be checked against an rds.json and targets.json that parse.
+	if rdsValid {
+		problems, err := staleShortcutProblems(configs)
+		if err != nil {
+			return err
+		}
+		for _, problem := range problems {
+			printProblem(problem, problem.Warning)
+		}
+	}
+
+	if errorCount > 0 {
+		return newExitError(ExitFailure, "found %d error(s) and %d warning(s)", errorCount, warningCount)
+	}
+	if warningCount > 0 {
+		fmt.Printf(Yellow("Found %d warning(s)\n"), warningCount)
+		return nil
+	}
+	fmt.Println(Green("✅ No problems found"))
+	return nil
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// wantProblem describes a configProblem expected by a test.
+type wantProblem struct {
+	line, col, entry int
+	warning          bool
+	message          string // Part of the message.
+}
+
+// checkProblems compares problems with the expected ones, in order.
+func checkProblems(t *testing.T, problems []configProblem, want []wantProblem) {
+	t.Helper()
+	if len(problems) != len(want) {
+		t.Fatalf("got %d problems, want %d:\n%v", len(problems), len(want), problems)
+	}
+	for i, w := range want {
+		p := problems[i]
+		if p.Line != w.line || p.Col != w.col || p.Entry != w.entry || p.Warning != w.warning || !strings.Contains(p.Message, w.message) {
+			t.Errorf("problem %d = %d:%d entry %d warning %v %q, want %d:%d entry %d warning %v %q",
+				i, p.Line, p.Col, p.Entry, p.Warning, p.Message, w.line, w.col, w.entry, w.warning, w.message)
+		}
+	}
+}
+
+func TestParseRDSConfigProblems(t *testing.T) {
+	data := strings.Join([]string{
+		`[`,
+		`  {"key": "a", "env": "dev", "type": "read", "endpoint": "a.x", "port": 5432, "local_port": 15432, "nmae": "x"},`,
+		`  {"key": "b", "env": "dev", "type": "read", "endpoint": "b.x", "port": "5432", "local_port": 15433},`,
+		`  {"key": "c", "env": "dev", "type": "Write", "port": 5432, "local_port": 15434},`,
+		`  {"key": "a", "env": "dev", "type": "read", "endpoint": "a2.x", "port": 5432, "local_port": 15435},`,
+		`  {"key": "d", "env": "dev", "type": "read", "endpoint": "d.x", "port": 5432, "local_port": 15432},`,
+		`  "oops"`,
+		`]`,
+	}, "\n")
+
+	configs, problems, err := parseRDSConfig([]byte(data), "rds.json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checkProblems(t, problems, []wantProblem{
+		{2, 100, 1, true, `unknown field "nmae"`},
+		{3, 65, 2, false, `"port" must be a number`},
+		{4, 3, 3, false, `"endpoint" is missing`},
+		{4, 30, 3, false, `"type" is "Write"`},
+		{5, 3, 4, false, "duplicates the key, env and type of entry 1"},
+		{6, 79, 5, true, `"local_port" 15432 is also used by entry 1`},
+		{7, 3, 6, false, "expected an object"},
+	})
+
+	var ids []string
+	for _, c := range configs {
+		ids = append(ids, c.ID())
+	}
+	if wantIDs := []string{"a|dev|read", "d|dev|read"}; !slices.Equal(ids, wantIDs) {
+		t.Errorf("got entries %v, want %v", ids, wantIDs)
+	}
+}
+
+func TestParseRDSConfigSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		line, col  int
+		suggestion string
+	}{
+		{"trailing comma", "[\n  {\"key\": \"a\"},\n]", 3, 1, "remove the trailing comma"},
+		{"missing comma", "[\n  {\"key\": \"a\"}\n  {\"key\": \"b\"}\n]", 3, 3, "add a comma"},
+		{"single quotes", "[\n  {'key': \"a\"}\n]", 2, 4, "double quotes"},
+		{"bare field name", "[\n  {key: \"a\"}\n]", 2, 4, "put field names in double quotes"},
+		{"missing colon", "[\n  {\"key\" \"a\"}\n]", 2, 10, "add a colon"},
+		{"unclosed list", "[\n  {\"key\": \"a\"}\n", 2, 15, "close every"},
+		{"not a list", "\n  {\"key\": \"a\"}", 2, 3, "wrap the entries in [ and ]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := parseRDSConfig([]byte(tt.data), "rds.json")
+			var p configProblem
+			if !errors.As(err, &p) {
+				t.Fatalf("got error %v, want a configProblem", err)
+			}
+			if p.Line != tt.line || p.Col != tt.col || !strings.Contains(p.Suggestion, tt.suggestion) {
+				t.Errorf("got %d:%d %q, want %d:%d %q", p.Line, p.Col, p.Suggestion, tt.line, tt.col, tt.suggestion)
+			}
+		})
+	}
+}
+
+func TestLineCol(t *testing.T) {
+	data := []byte("ab\ncd\n\nef")
+	tests := []struct{ offset, line, col int }{
+		{0, 1, 1},
+		{1, 1, 2},
+		{3, 2, 1},
+		{6, 3, 1},
+		{8, 4, 2},
+		{100, 4, 3},
+	}
+	for _, tt := range tests {
+		if line, col := lineCol(data, tt.offset); line != tt.line || col != tt.col {
+			t.Errorf("lineCol(%d) = %d:%d, want %d:%d", tt.offset, line, col, tt.line, tt.col)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Reconnect backoff: the delay doubles after each failed attempt, up to
+// reconnectMaxDelay, and is reset once a session stayed up for
+// reconnectStableAfter.
+const (
+	reconnectInitialDelay = time.Second
+	reconnectMaxDelay     = 30 * time.Second
+	reconnectStableAfter  = time.Minute
+)
+
+// runSupervisedSessions runs port forwarding sessions side by side until the
+// user interrupts them with Ctrl+C. With reconnect set, a session that exits
+// unexpectedly is restarted with backoff, keeping the same local port so
+// database clients can reconnect on their own; otherwise a failing session
+// closes the others.
+func runSupervisedSessions(profile string, sessions [][]string, reconnect bool) error {
+	var interrupted atomic.Bool
+	current := make([]atomic.Pointer[os.Process], len(sessions))
+	stopAll := func(sig os.Signal) {
+		interrupted.Store(true)
+		for i := range current {
+			if p := current[i].Load(); p != nil {
+				if runtime.GOOS == "windows" {
+					p.Kill()
+				} else {
+					p.Signal(sig)
+				}
+			}
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		// Ctrl+C in a terminal already reaches the sessions; a signal
+		// sent to asmago alone must be passed on.
+		for sig := range signals {
+			stopAll(sig)
+		}
+	}()
+
+	if reconnect {
+		if len(sessions) == 1 {
+			fmt.Println(Cyan("ℹ️  Auto-reconnect is on. Press Ctrl+C to close the tunnel."))
+		} else {
+			fmt.Println(Cyan("ℹ️  Auto-reconnect is on. Press Ctrl+C to close the tunnels."))
+		}
+	} else if len(sessions) > 1 {
+		fmt.Println(Cyan("ℹ️  Press Ctrl+C to close the tunnels."))
+	}
+
+	refresher := refresherFor(profile)
+	errs := make([]error, len(sessions))
+	var wg sync.WaitGroup
+	for i, args := range sessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = superviseSession(args, &current[i], &interrupted, refresher, reconnect)
+			if errs[i] != nil {
+				stopAll(os.Interrupt)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// superviseSession runs one port forwarding session of runSupervisedSessions.
+// A session that fails before accepting connections because of an expired
+// token is retried after refreshing the SSO token. Missing credentials,
+// denied access and an unreachable instance end it, even with reconnect.
+func superviseSession(args []string, current *atomic.Pointer[os.Process], interrupted *atomic.Bool, refresher *ssoRefresher, reconnect bool) error {
+	delay := reconnectInitialDelay
+	for attempt := 1; ; attempt++ {
+		started := time.Now()
+		ready, stderr, err := runPortForwardingSession(refresher.profile, args, current)
+		if interrupted.Load() {
+			return nil
+		}
+
+		failure := failureUnknown
+		if err != nil && !ready {
+			failure = classifyAWSOutput(stderr)
+		}
+		switch failure {
+		case failureNoCredentials, failureAccessDenied, failureTargetNotConnected:
+			return withExitCode(ExitAWS, newAWSCLIError(refresher.profile, stderr, err))
+		}
+
+		if !reconnect {
+			if err == nil {
+				return nil
+			}
+			if ready {
+				return fmt.Errorf("port forwarding session failed: %v", err)
+			}
+			if failure != failureExpiredToken || attempt > 1 {
+				return withExitCode(ExitAWS, newAWSCLIError(refresher.profile, stderr, err))
+			}
+			if canRetry, refreshErr := refresher.refresh(started); refreshErr != nil {
+				return refreshErr
+			} else if !canRetry {
+				return withExitCode(ExitAWS, newAWSCLIError(refresher.profile, stderr, err))
+			}
+			fmt.Println(Cyan("🔁 Retrying..."))
+			continue
+		}
+
+		if time.Since(started) >= reconnectStableAfter {
+			delay = reconnectInitialDelay
+		}
+		if err != nil {
+			fmt.Printf(Yellow("\n⚠️  Port forwarding session ended unexpectedly (%v).\n"), err)
+		} else {
+			fmt.Println(Yellow("\n⚠️  Port forwarding session ended unexpectedly."))
+		}
+
+		if failure == failureExpiredToken {
+			if canRetry, refreshErr := refresher.refresh(started); refreshErr != nil {
+				return refreshErr
+			} else if !canRetry {
+				return withExitCode(ExitAWS, newAWSCLIError(refresher.profile, stderr, err))
+			}
+		} else if !ready && attempt == 1 && failure != failureThrottled {
+			return withExitCode(ExitAWS, newAWSCLIError(refresher.profile, stderr, err))
+		}
+
+		fmt.Printf(Cyan("🔁 Reconnecting in %s (attempt %d)...\n"), delay, attempt)
+		if !sleepUnlessInterrupted(delay, interrupted) {
+			return nil
+		}
+		if err := ensureSSOToken(refresher.profile, 0); err != nil {
+			return err
+		}
+		delay = min(delay*2, reconnectMaxDelay)
+	}
+}
+
+// ssoRefresher refreshes the SSO token of a profile on behalf of sessions
+// running side by side, so that sessions failing together refresh only once.
+type ssoRefresher struct {
+	profile     string
+	mu          sync.Mutex
+	refreshedAt time.Time
+}
+
+// refresh refreshes the SSO token unless it was already refreshed after since.
+func (r *ssoRefresher) refresh(since time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.refreshedAt.After(since) {
+		return true, nil
+	}
+	canRetry, err := executeRefreshProfileAction(r.profile)
+	if canRetry {
+		r.refreshedAt = time.Now()
+	}
+	return canRetry, err
+}
+
+// runPortForwardingSession runs one 'aws ssm start-session' port forwarding
+// session in the foreground, publishing its process in current while it runs.
+// It reports whether the session became ready to accept connections and
+// returns the captured standard error.
+func runPortForwardingSession(profile string, args []string, current *atomic.Pointer[os.Process]) (bool, string, error) {
+	cmd, err := newAWSCommand(profile, args)
+	if err != nil {
+		return false, "", err
+	}
+	var stderrBuf bytes.Buffer
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderrBuf)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return false, "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return false, "", fmt.Errorf("failed to start AWS CLI: %w", err)
+	}
+	current.Store(cmd.Process)
+	defer current.Store(nil)
+
+	ready := false
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		fmt.Println(line)
+		if strings.Contains(line, tunnelReadyMarker) {
+			ready = true
+		}
+	}
+	io.Copy(os.Stdout, stdout)
+
+	err = cmd.Wait()
+	return ready, strings.TrimSpace(stderrBuf.String()), err
+}
+
+// sleepUnlessInterrupted waits for d and reports false if the user
+// interrupted the wait.
+func sleepUnlessInterrupted(d time.Duration, interrupted *atomic.Bool) bool {
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+		if interrupted.Load() {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return !interrupted.Load()
+}
@@ -0,0 +1,253 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Settings holds user-tunable options. Each option is read from config.yaml
+// under the key in parentheses, and can be overridden through the ASMAGO_*
+// environment variable that follows it.
+type Settings struct {
+	// DefaultRegion is offered when a profile has no region
+	// (defaults.region, ASMAGO_DEFAULT_REGION).
+	DefaultRegion string
+
+	// ShortcutLimit is the number of shortcuts shown in the picker, not
+	// counting pinned ones (ui.shortcut_limit, ASMAGO_SHORTCUT_LIMIT).
+	ShortcutLimit int
+
+	// PageSize is the number of items shown at once by every picker
+	// (ui.page_size, ASMAGO_PAGE_SIZE).
+	PageSize int
+
+	// ReplacementPolicy decides what happens when a shortcut's instance is
+	// gone and several running instances could replace it: "ask", "newest"
+	// or "fail" (defaults.replacement_policy, ASMAGO_REPLACEMENT_POLICY).
+	ReplacementPolicy string
+
+	// RDSDiscovery enables discovery of RDS/Aurora endpoints through the
+	// AWS API, in addition to rds.json (defaults.rds_discovery,
+	// ASMAGO_RDS_DISCOVERY).
+	RDSDiscovery bool
+
+	// RDSDiscoveryTTL is how long discovered endpoints are cached on disk
+	// (defaults.rds_discovery_ttl, ASMAGO_RDS_DISCOVERY_TTL, e.g. "30m";
+	// "0" always queries AWS).
+	RDSDiscoveryTTL time.Duration
+
+	// PortConflict decides what happens when the local port of a tunnel is
+	// already in use: "next" picks the next free port, "fail" stops with an
+	// error (defaults.port_conflict, ASMAGO_PORT_CONFLICT).
+	PortConflict string
+
+	// Reconnect restarts RDS port forwarding sessions that drop, until
+	// Ctrl+C is pressed (defaults.reconnect, ASMAGO_RECONNECT).
+	Reconnect bool
+
+	// SSORefreshWindow is how long before its expiry the SSO token is
+	// refreshed when a session or tunnel starts (defaults.sso_refresh_window,
+	// ASMAGO_SSO_REFRESH_WINDOW, e.g. "10m"; "0" refreshes only expired tokens).
+	SSORefreshWindow time.Duration
+
+	// DataDir replaces ~/.local/share/asmago as the directory of shortcuts,
+	// usage data and caches (data_dir, ASMAGO_DATA_DIR).
+	DataDir string
+
+	// EnvRules map instances to the environment of their targets
+	// (env_rules). Nil means the default rules.
+	EnvRules []envRule
+
+	// Profiles holds preferences by AWS profile name (profiles.<name>).
+	Profiles map[string]profileSettings
+}
+
+// profileSettings holds the preferences of one AWS profile.
+type profileSettings struct {
+	Region       string // Used instead of the region in ~/.aws/config.
+	Env          string // Environment whose targets are offered, like --env.
+	RDSDiscovery *bool  // Overrides defaults.rds_discovery when set.
+}
+
+// profileSettingKeys lists the keys of a profileSettings entry.
+var profileSettingKeys = []string{"region", "env", "rds_discovery"}
+
+// settingKey describes an option of Settings: its key in config.yaml, its
+// environment variable, and the allowed values.
+type settingKey struct {
+	Key     string
+	Env     string
+	field   func(s *Settings) any // Pointer to the field holding the option.
+	min     int                   // Minimum of an integer option.
+	choices []string              // Allowed values of a string option, if restricted.
+}
+
+// settingKeys lists the options set through a single config.yaml key, in the
+// order they are documented.
+var settingKeys = []settingKey{
+	{Key: "defaults.region", Env: "ASMAGO_DEFAULT_REGION", field: func(s *Settings) any { return &s.DefaultRegion }},
+	{Key: "defaults.replacement_policy", Env: "ASMAGO_REPLACEMENT_POLICY", field: func(s *Settings) any { return &s.ReplacementPolicy }, choices: []string{replacementAsk, replacementNewest, replacementFail}},
+	{Key: "defaults.rds_discovery", Env: "ASMAGO_RDS_DISCOVERY", field: func(s *Settings) any { return &s.RDSDiscovery }},
+	{Key: "defaults.rds_discovery_ttl", Env: "ASMAGO_RDS_DISCOVERY_TTL", field: func(s *Settings) any { return &s.RDSDiscoveryTTL }},
+	{Key: "defaults.port_conflict", Env: "ASMAGO_PORT_CONFLICT", field: func(s *Settings) any { return &s.PortConflict }, choices: []string{portConflictNext, portConflictFail}},
+	{Key: "defaults.reconnect", Env: "ASMAGO_RECONNECT", field: func(s *Settings) any { return &s.Reconnect }},
+	{Key: "defaults.sso_refresh_window", Env: "ASMAGO_SSO_REFRESH_WINDOW", field: func(s *Settings) any { return &s.SSORefreshWindow }},
+	{Key: "ui.shortcut_limit", Env: "ASMAGO_SHORTCUT_LIMIT", field: func(s *Settings) any { return &s.ShortcutLimit }, min: 1},
+	{Key: "ui.page_size", Env: "ASMAGO_PAGE_SIZE", field: func(s *Settings) any { return &s.PageSize }, min: 1},
+	{Key: "data_dir", Env: "ASMAGO_DATA_DIR", field: func(s *Settings) any { return &s.DataDir }},
+}
+
+// findSettingKey returns the option stored under a config.yaml key.
+func findSettingKey(key string) (settingKey, bool) {
+	for _, k := range settingKeys {
+		if k.Key == key {
+			return k, true
+		}
+	}
+	return settingKey{}, false
+}
+
+// set parses value and stores it in the option of s.
+func (k settingKey) set(s *Settings, value string) error {
+	switch target := k.field(s).(type) {
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < k.min {
+			return fmt.Errorf("expected a number of at least %d", k.min)
+		}
+		*target = n
+	case *string:
+		if k.choices != nil {
+			value = strings.ToLower(value)
+			if !slices.Contains(k.choices, value) {
+				return fmt.Errorf("expected one of: %s", strings.Join(k.choices, ", "))
+			}
+		}
+		*target = value
+	case *bool:
+		b, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		*target = b
+	case *time.Duration:
+		d, err := parseDuration(value)
+		if err != nil {
+			return err
+		}
+		*target = d
+	}
+	return nil
+}
+
+// get returns the option of s formatted as it is written in config.yaml.
+func (k settingKey) get(s Settings) string {
+	switch value := k.field(&s).(type) {
+	case *int:
+		return strconv.Itoa(*value)
+	case *string:
+		return *value
+	case *bool:
+		return strconv.FormatBool(*value)
+	case *time.Duration:
+		return formatDuration(*value)
+	}
+	return ""
+}
+
+// defaultSettings returns the settings used when nothing is configured.
+func defaultSettings() Settings {
+	return Settings{
+		DefaultRegion:     "ap-southeast-1",
+		ShortcutLimit:     5,
+		PageSize:          10,
+		ReplacementPolicy: replacementAsk,
+		RDSDiscovery:      true,
+		RDSDiscoveryTTL:   time.Hour,
+		PortConflict:      portConflictNext,
+		SSORefreshWindow:  10 * time.Minute,
+	}
+}
+
+var (
+	settingsOnce sync.Once
+	settings     Settings
+)
+
+// loadSettings returns the default settings overridden by config.yaml, then
+// by the environment. They are read once per run; problems are reported as
+// warnings and the faulty values ignored.
+func loadSettings() Settings {
+	settingsOnce.Do(func() {
+		settings = defaultSettings()
+		if path, err := ConfigFilePath(); err == nil {
+			for _, problem := range applyConfigFile(&settings, path) {
+				fmt.Fprintf(os.Stderr, Yellow("Warning: %v\n"), problem)
+			}
+		}
+		for _, k := range settingKeys {
+			value, ok := os.LookupEnv(k.Env)
+			if !ok || value == "" {
+				continue
+			}
+			if err := k.set(&settings, value); err != nil {
+				fmt.Fprintf(os.Stderr, Yellow("Warning: ignoring %s=%q, %v\n"), k.Env, value, err)
+			}
+		}
+	})
+	return settings
+}
+
+// profile returns the preferences of an AWS profile.
+func (s Settings) profile(name string) profileSettings {
+	return s.Profiles[name]
+}
+
+// rdsDiscoveryFor reports whether RDS discovery is enabled for a profile.
+func (s Settings) rdsDiscoveryFor(profile string) bool {
+	if enabled := s.profile(profile).RDSDiscovery; enabled != nil {
+		return *enabled
+	}
+	return s.RDSDiscovery
+}
+
+// parseBool parses the boolean spellings accepted in settings.
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "on":
+		return true, nil
+	case "0", "false", "no", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("expected true or false")
+}
+
+// parseDuration parses a non-negative duration such as "30m"; "0" needs no unit.
+func parseDuration(value string) (time.Duration, error) {
+	if value == "0" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("expected a duration such as 30m or 2h")
+	}
+	return d, nil
+}
+
+// formatDuration formats a duration without trailing zero units, e.g. "1h"
+// rather than "1h0m0s".
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"fmt"
+)
+
+// ListShortcuts displays the shortcuts shown in the picker, or every saved
+// shortcut when all is true.
+func (a *App) ListShortcuts(all bool) error {
+	var shortcutList []Shortcut
+	if all {
+		shortcutList = a.shortcutMgr.sortedShortcuts()
+	} else {
+		shortcutList, _ = a.shortcutMgr.getDisplayList()
+	}
+	if len(shortcutList) == 0 {
+		fmt.Println(Yellow("No shortcuts found."))
+		return nil
+	}
+
+	if all {
+		fmt.Println(Cyan("All Shortcuts:"))
+	} else {
+		fmt.Printf(Cyan("Top %d Shortcuts:\n"), a.shortcutMgr.limit)
+	}
+	for i, sc := range shortcutList {
+		usage := ""
+		if all {
+			usage = fmt.Sprintf(" [%s, used %dx]", sc.InstanceID, sc.UsageCount)
+		}
+		fmt.Printf(" %d. %s%s%s\n", i+1, sc.DisplayString, usage, Yellow(a.shortcutMgr.remark(sc)))
+	}
+	return nil
+}
+
+// RemoveShortcuts deletes the shortcuts referenced by name or by their
+// position in 'asmago shortcuts list --all'.
+func (a *App) RemoveShortcuts(refs []string) error {
+	// Resolve every reference first so positions refer to the same list.
+	keys := make([]string, len(refs))
+	for i, ref := range refs {
+		key, err := a.shortcutMgr.resolve(ref)
+		if err != nil {
+			return err
+		}
+		keys[i] = key
+	}
+
+	for _, key := range keys {
+		sc, ok := a.shortcutMgr.shortcuts[key]
+		if !ok {
+			continue // Referenced twice.
+		}
+		if err := a.shortcutMgr.remove(key); err != nil {
+			return fmt.Errorf("failed to save shortcut data: %w", err)
+		}
+		fmt.Printf(Green("✅ Removed shortcut: %s\n"), sc.DisplayString)
+	}
+	return nil
+}
+
+// RenameShortcut gives a shortcut a new name.
+func (a *App) RenameShortcut(ref, name string) error {
+	key, err := a.shortcutMgr.resolve(ref)
+	if err != nil {
+		return err
+	}
+	if err := a.shortcutMgr.rename(key, name); err != nil {
+		return &ExitError{Code: ExitUsage, Err: err}
+	}
+	fmt.Printf(Green("✅ Shortcut renamed: %s\n"), a.shortcutMgr.shortcuts[key].DisplayString)
+	return nil
+}
+
+// PinShortcut pins or unpins a shortcut. Pinned shortcuts always appear in
+// the shortcut picker, whatever their usage count.
+func (a *App) PinShortcut(ref string, pinned bool) error {
+	key, err := a.shortcutMgr.resolve(ref)
+	if err != nil {
+		return err
+	}
+	if err := a.shortcutMgr.setPinned(key, pinned); err != nil {
+		return fmt.Errorf("failed to save shortcut data: %w", err)
+	}
+	if pinned {
+		fmt.Printf(Green("📌 Shortcut pinned: %s\n"), a.shortcutMgr.shortcuts[key].DisplayString)
+	} else {
+		fmt.Printf(Green("✅ Shortcut unpinned: %s\n"), a.shortcutMgr.shortcuts[key].DisplayString)
+	}
+	return nil
+}
@@ -5,18 +5,23 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // ShortcutManager manages shortcut data.
 type ShortcutManager struct {
 	shortcuts   map[string]Shortcut
 	lastUsedKey string
+	limit       int // Number of shortcuts shown in the picker, besides pinned ones.
 }
 
-// newShortcutManager creates a new instance of ShortcutManager.
-func newShortcutManager() (*ShortcutManager, error) {
+// newShortcutManager creates a new instance of ShortcutManager that shows
+// limit shortcuts in the picker.
+func newShortcutManager(limit int) (*ShortcutManager, error) {
 	shortcuts, err := loadShortcuts()
 	if err != nil {
 		return nil, err
@@ -25,37 +30,127 @@ func newShortcutManager() (*ShortcutManager, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &ShortcutManager{shortcuts: shortcuts, lastUsedKey: lastUsedKey}, nil
+	return &ShortcutManager{shortcuts: shortcuts, lastUsedKey: lastUsedKey, limit: limit}, nil
 }
 
-// addOrUpdate adds a new shortcut or updates the usage count of an existing one.
-func (sm *ShortcutManager) addOrUpdate(shortcut Shortcut) {
-	key := fmt.Sprintf("%s;%s;%s;%s", shortcut.Profile, shortcut.InstanceID, shortcut.Action, shortcut.RDS_ID)
+// shortcutNamePattern restricts shortcut names to characters that are safe to
+// type on a command line and to complete in a shell.
+var shortcutNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
 
+// shortcutKey returns the key under which a shortcut is stored.
+func shortcutKey(sc Shortcut) string {
+	key := fmt.Sprintf("%s;%s;%s;%s", sc.Profile, sc.InstanceID, sc.Action, sc.RDS_ID)
+	if sc.TargetID != "" {
+		key += ";" + sc.TargetID
+	}
+	if len(sc.PortPairs) > 0 {
+		key += ";" + formatPortPairs(sc.PortPairs)
+	}
+	if sc.Command != "" {
+		key += ";" + sc.Command
+	}
+	return key
+}
+
+// buildDisplayString returns the text shown for a shortcut in lists and prompts.
+func buildDisplayString(sc Shortcut) string {
 	var rdsPart string
-	if shortcut.Action == "Connect RDS" {
-		rdsArr := strings.Split(shortcut.RDS_ID, "|")
+	switch sc.Action {
+	case actionRDS:
+		rdsArr := strings.Split(sc.RDS_ID, "|")
 		rdsKey := rdsArr[0]
 		rdsType := rdsArr[2]
 		rdsPart = fmt.Sprintf(" -> Connect RDS (%s - %s)", rdsKey, rdsType)
-	} else {
-		rdsPart = " -> Start Session (SSM)"
+	case actionForward:
+		kind, key := parseTargetID(sc.TargetID)
+		rdsPart = fmt.Sprintf(" -> Forward (%s - %s)", key, kind)
+	case actionInstancePort:
+		rdsPart = fmt.Sprintf(" -> Forward Instance Port (%s)", strings.ReplaceAll(formatPortPairs(sc.PortPairs), ",", ", "))
+	case actionRunCommand:
+		command := sc.Command
+		if len(command) > 40 {
+			command = command[:37] + "..."
+		}
+		rdsPart = fmt.Sprintf(" -> Run Command (%s)", command)
+	default:
+		rdsPart = " -> " + actionSSM
 	}
-	shortcut.DisplayString = fmt.Sprintf("%s -> %s%s", shortcut.Profile, shortcut.InstanceName, rdsPart)
+	display := fmt.Sprintf("%s -> %s%s", sc.Profile, sc.InstanceName, rdsPart)
+	if sc.Name != "" {
+		display = fmt.Sprintf("[%s] %s", sc.Name, display)
+	}
+	return display
+}
 
+// addOrUpdate adds a new shortcut or updates the usage count of an existing one.
+// An existing name is kept unless the given shortcut carries a new one.
+func (sm *ShortcutManager) addOrUpdate(shortcut Shortcut) {
+	key := shortcutKey(shortcut)
+
+	now := time.Now()
 	if sc, ok := sm.shortcuts[key]; ok {
-		sc.UsageCount++
-		sc.DisplayString = shortcut.DisplayString
+		record := sc.usage()
+		record.recordUse(now)
+		sc.UsageCount, sc.Uses = record.Count, record.Uses
+		if shortcut.Name != "" {
+			sc.Name = shortcut.Name
+		}
+		sc.InstanceName = shortcut.InstanceName
+		sc.AutoScalingGroup = shortcut.AutoScalingGroup
+		sc.DisplayString = buildDisplayString(sc)
 		sm.shortcuts[key] = sc
 	} else {
-		shortcut.UsageCount = 1
+		shortcut.UsageCount, shortcut.Uses = 1, []time.Time{now}
+		shortcut.DisplayString = buildDisplayString(shortcut)
 		sm.shortcuts[key] = shortcut
 	}
 
 	sm.lastUsedKey = key
 	if err := sm.save(); err != nil {
-		fmt.Println(Yellow("Warning: Failed to save shortcut data: %v", err))
+		fmt.Printf(Yellow("Warning: Failed to save shortcut data: %v\n"), err)
+	}
+}
+
+// exists reports whether a shortcut with the same key is already saved.
+func (sm *ShortcutManager) exists(shortcut Shortcut) bool {
+	_, ok := sm.shortcuts[shortcutKey(shortcut)]
+	return ok
+}
+
+// findByName returns the shortcut with the given name.
+func (sm *ShortcutManager) findByName(name string) (*Shortcut, bool) {
+	for _, sc := range sm.shortcuts {
+		if sc.Name == name {
+			return &sc, true
+		}
 	}
+	return nil, false
+}
+
+// validateName checks that a name is well-formed and not used by another
+// shortcut than the one stored under key.
+func (sm *ShortcutManager) validateName(key, name string) error {
+	if !shortcutNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid shortcut name '%s': use letters, digits, '.', '_' or '-'", name)
+	}
+	for k, sc := range sm.shortcuts {
+		if sc.Name == name && k != key {
+			return fmt.Errorf("shortcut name '%s' is already used by: %s", name, sc.DisplayString)
+		}
+	}
+	return nil
+}
+
+// names returns the names of all named shortcuts, sorted alphabetically.
+func (sm *ShortcutManager) names() []string {
+	var names []string
+	for _, sc := range sm.shortcuts {
+		if sc.Name != "" {
+			names = append(names, sc.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
 }
 
 // save persists the shortcut data to disk.
@@ -85,49 +180,216 @@ func (sm *ShortcutManager) save() error {
 	return os.WriteFile(lastShortcutPath, []byte(sm.lastUsedKey), 0644)
 }
 
+// remark returns the annotation shown after a shortcut in lists, such as
+// " (pinned)" or " (last used)".
+func (sm *ShortcutManager) remark(sc Shortcut) string {
+	var parts []string
+	if sc.Pinned {
+		parts = append(parts, "pinned")
+	}
+	if sm.lastUsedKey != "" && shortcutKey(sc) == sm.lastUsedKey {
+		parts = append(parts, "last used")
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// sortByUsage sorts shortcuts by frecency, highest first. Ties are broken
+// by display string so the order is stable between runs.
+func sortByUsage(list []Shortcut) {
+	sort.Slice(list, func(i, j int) bool { return list[i].DisplayString < list[j].DisplayString })
+	sortByFrecency(list, Shortcut.usage)
+}
+
+// sortedShortcuts returns every shortcut, pinned ones first, then by frecency.
+func (sm *ShortcutManager) sortedShortcuts() []Shortcut {
+	var pinned, others []Shortcut
+	for _, sc := range sm.shortcuts {
+		if sc.Pinned {
+			pinned = append(pinned, sc)
+		} else {
+			others = append(others, sc)
+		}
+	}
+	sortByUsage(pinned)
+	sortByUsage(others)
+	return append(pinned, others...)
+}
+
 // getDisplayList prepares the sorted list of shortcuts for display.
+// Pinned shortcuts are always included, followed by the last used shortcut
+// and the highest ranked ones until the configured limit is reached.
 func (sm *ShortcutManager) getDisplayList() ([]Shortcut, []string) {
 	if len(sm.shortcuts) == 0 {
 		return nil, nil
 	}
 
+	var pinned []Shortcut
 	var mostFrequent []Shortcut
 	var lastUsedShortcut *Shortcut
 	var finalShortcutList []Shortcut
 	var displayItems []string
 
 	if sm.lastUsedKey != "" {
-		if sc, ok := sm.shortcuts[sm.lastUsedKey]; ok {
+		if sc, ok := sm.shortcuts[sm.lastUsedKey]; ok && !sc.Pinned {
 			lastUsedShortcut = &sc
 		}
 	}
 
 	for key, sc := range sm.shortcuts {
+		if sc.Pinned {
+			pinned = append(pinned, sc)
+			continue
+		}
 		if lastUsedShortcut != nil && key == sm.lastUsedKey {
 			continue
 		}
 		mostFrequent = append(mostFrequent, sc)
 	}
-	sort.Slice(mostFrequent, func(i, j int) bool {
-		return mostFrequent[i].UsageCount > mostFrequent[j].UsageCount
-	})
+	sortByUsage(pinned)
+	sortByUsage(mostFrequent)
 
+	finalShortcutList = append(finalShortcutList, pinned...)
 	if lastUsedShortcut != nil {
 		finalShortcutList = append(finalShortcutList, *lastUsedShortcut)
-		displayItems = append(displayItems, lastUsedShortcut.DisplayString+" (last used)")
 	}
 
-	remainingSlots := 5 - len(finalShortcutList)
+	remainingSlots := sm.limit - (len(finalShortcutList) - len(pinned))
 	if remainingSlots > 0 && len(mostFrequent) > 0 {
 		limit := remainingSlots
 		if len(mostFrequent) < limit {
 			limit = len(mostFrequent)
 		}
 		finalShortcutList = append(finalShortcutList, mostFrequent[:limit]...)
-		for i := 0; i < limit; i++ {
-			displayItems = append(displayItems, mostFrequent[i].DisplayString)
-		}
 	}
 
+	for _, sc := range finalShortcutList {
+		displayItems = append(displayItems, sc.DisplayString+sm.remark(sc))
+	}
 	return finalShortcutList, displayItems
 }
+
+// replaceInstance points a shortcut at a different instance, keeping its
+// name, pin and usage history. The shortcut is updated in place.
+func (sm *ShortcutManager) replaceInstance(sc *Shortcut, inst EC2Instance) error {
+	oldKey := shortcutKey(*sc)
+	updated := *sc
+	if stored, ok := sm.shortcuts[oldKey]; ok {
+		updated = stored
+	}
+	updated.InstanceID = inst.ID
+	updated.InstanceName = inst.displayName()
+	updated.AutoScalingGroup = inst.autoScalingGroup()
+	newKey := shortcutKey(updated)
+
+	// Another shortcut may already point at the replacement instance.
+	if existing, ok := sm.shortcuts[newKey]; ok && newKey != oldKey {
+		merged := existing.usage()
+		merged.Count += updated.UsageCount
+		merged.Uses = append(merged.Uses, updated.Uses...)
+		sort.Slice(merged.Uses, func(i, j int) bool { return merged.Uses[i].Before(merged.Uses[j]) })
+		if len(merged.Uses) > maxRecordedUses {
+			merged.Uses = merged.Uses[len(merged.Uses)-maxRecordedUses:]
+		}
+		updated.UsageCount, updated.Uses = merged.Count, merged.Uses
+		if updated.Name == "" {
+			updated.Name = existing.Name
+		}
+		updated.Pinned = updated.Pinned || existing.Pinned
+	}
+	updated.DisplayString = buildDisplayString(updated)
+
+	delete(sm.shortcuts, oldKey)
+	sm.shortcuts[newKey] = updated
+	if sm.lastUsedKey == oldKey {
+		sm.lastUsedKey = newKey
+	}
+	*sc = updated
+	return sm.save()
+}
+
+// resolve finds a shortcut by name, or by its 1-based position in the
+// list printed by 'asmago shortcuts list --all'. It returns the storage key.
+func (sm *ShortcutManager) resolve(ref string) (string, error) {
+	if sc, ok := sm.findByName(ref); ok {
+		return shortcutKey(*sc), nil
+	}
+	if index, err := strconv.Atoi(ref); err == nil {
+		all := sm.sortedShortcuts()
+		if index < 1 || index > len(all) {
+			return "", newExitError(ExitNotFound, "shortcut index %d is out of range (1-%d)", index, len(all))
+		}
+		return shortcutKey(all[index-1]), nil
+	}
+	return "", newExitError(ExitNotFound, "no shortcut named '%s'; see 'asmago shortcuts list --all'", ref)
+}
+
+// remove deletes the shortcut stored under key.
+func (sm *ShortcutManager) remove(key string) error {
+	delete(sm.shortcuts, key)
+	if sm.lastUsedKey == key {
+		sm.lastUsedKey = ""
+	}
+	return sm.save()
+}
+
+// rename gives the shortcut stored under key a new name.
+func (sm *ShortcutManager) rename(key, name string) error {
+	if err := sm.validateName(key, name); err != nil {
+		return err
+	}
+	sc := sm.shortcuts[key]
+	sc.Name = name
+	sc.DisplayString = buildDisplayString(sc)
+	sm.shortcuts[key] = sc
+	return sm.save()
+}
+
+// setPinned pins or unpins the shortcut stored under key.
+func (sm *ShortcutManager) setPinned(key string, pinned bool) error {
+	sc := sm.shortcuts[key]
+	sc.Pinned = pinned
+	sm.shortcuts[key] = sc
+	return sm.save()
+}
+
+// renameRDSTarget points the shortcuts of an RDS target at its new ID. A
+// shortcut is left alone if one already exists under its new key. It returns
+// the number of shortcuts updated.
+func (sm *ShortcutManager) renameRDSTarget(oldID, newID string) (int, error) {
+	moved := 0
+	for oldKey, sc := range sm.shortcuts {
+		if sc.RDS_ID != oldID {
+			continue
+		}
+		sc.RDS_ID = newID
+		newKey := shortcutKey(sc)
+		if _, ok := sm.shortcuts[newKey]; ok {
+			continue
+		}
+		sc.DisplayString = buildDisplayString(sc)
+		delete(sm.shortcuts, oldKey)
+		sm.shortcuts[newKey] = sc
+		if sm.lastUsedKey == oldKey {
+			sm.lastUsedKey = newKey
+		}
+		moved++
+	}
+	if moved == 0 {
+		return 0, nil
+	}
+	return moved, sm.save()
+}
+
+// countRDSTarget returns the number of shortcuts using an RDS target.
+func (sm *ShortcutManager) countRDSTarget(id string) int {
+	n := 0
+	for _, sc := range sm.shortcuts {
+		if sc.RDS_ID == id {
+			n++
+		}
+	}
+	return n
+}
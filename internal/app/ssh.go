@@ -0,0 +1,263 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sshConfigHeader starts the managed ssh config file.
+const sshConfigHeader = "# Managed by asmago. Do not edit: run 'asmago ssh-config' to regenerate."
+
+// ProxyOptions holds the values of the 'proxy' subcommand.
+type ProxyOptions struct {
+	Host    string // Shortcut name, instance ID or Name tag.
+	Profile string // Required unless Host is a shortcut name.
+	Region  string
+	Port    int
+
+	// Stdout carries the SSH stream. The caller sends os.Stdout to standard
+	// error before initializing the application, so that nothing printed
+	// ends up in the stream; nil means os.Stdout.
+	Stdout *os.File
+}
+
+// SSHConfigOptions holds the values of the 'ssh-config' subcommand.
+type SSHConfigOptions struct {
+	Profiles []string // Profiles to generate Host blocks for; all profiles when empty.
+	Output   string   // Path of the managed include file.
+	User     string   // Optional User for every Host block.
+	Include  bool     // Add an Include line for Output to ~/.ssh/config.
+}
+
+// DefaultSSHConfigPath returns the default path of the managed include file.
+func DefaultSSHConfigPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".ssh", "asmago_config")
+	}
+	return filepath.Join(homeDir, ".ssh", "asmago_config")
+}
+
+// Proxy connects standard input and output to the SSH port of an instance
+// through the AWS-StartSSHSession document. It is meant to be used as an
+// OpenSSH ProxyCommand.
+func (a *App) Proxy(opts ProxyOptions) error {
+	// Standard input and output belong to SSH, so a replacement instance
+	// cannot be chosen from a prompt, nor an MFA code asked for.
+	if a.settings.ReplacementPolicy == replacementAsk {
+		a.settings.ReplacementPolicy = replacementNewest
+	}
+	mfaPromptUnavailable = true
+
+	stdout := opts.Stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	profile, region, instanceID, err := a.resolveHost(opts.Host, opts.Profile, opts.Region)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"ssm", "start-session", "--target", instanceID, "--profile", profile, "--region", region, "--document-name", "AWS-StartSSHSession", "--parameters", fmt.Sprintf("portNumber=%d", opts.Port)}
+	if a.DryRun {
+		fmt.Fprintln(os.Stderr, Cyan("-- DRY RUN MODE --"))
+		fmt.Fprintln(os.Stderr, Yellow("aws "+strings.Join(args, " ")))
+		return nil
+	}
+
+	cmd, err := newAWSCommand(profile, args)
+	if err != nil {
+		return err
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return withExitCode(ExitAWS, fmt.Errorf("SSH session to %s failed: %w", instanceID, err))
+	}
+	return nil
+}
+
+// resolveHost resolves a host, as used by 'proxy' and 'cp', to a profile,
+// region and instance. The host is looked up as a shortcut name first, then as
+// an instance ID or Name tag in the given profile.
+func (a *App) resolveHost(host, profile, regionOverride string) (string, string, string, error) {
+	if sc, ok := a.shortcutMgr.findByName(host); ok && (profile == "" || profile == sc.Profile) {
+		region, err := resolveRegion(sc.Profile, regionOverride)
+		if err != nil {
+			return "", "", "", err
+		}
+		if err := a.resolveShortcutInstance(sc, region); err != nil {
+			return "", "", "", err
+		}
+		return sc.Profile, region, sc.InstanceID, nil
+	}
+
+	if profile == "" {
+		profile = defaultProfile()
+	}
+	if profile == "" {
+		return "", "", "", newExitError(ExitUsage, "'%s' is not a shortcut name; pass --profile to look it up as an instance", host)
+	}
+	region, err := resolveRegion(profile, regionOverride)
+	if err != nil {
+		return "", "", "", err
+	}
+	instances, err := fetchRunningInstances(profile, region)
+	if err != nil {
+		return "", "", "", withExitCode(ExitAWS, err)
+	}
+	instance, err := resolveInstance(instances, host)
+	if err != nil {
+		return "", "", "", err
+	}
+	return profile, region, instance.ID, nil
+}
+
+// WriteSSHConfig writes Host blocks for named shortcuts and for the running
+// instances of the selected profiles into a managed ssh config file, so that
+// 'ssh', 'scp', 'rsync' and git can reach instances through 'asmago proxy'.
+func (a *App) WriteSSHConfig(opts SSHConfigOptions) error {
+	executablePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get application path: %w", err)
+	}
+
+	profiles := opts.Profiles
+	if len(profiles) == 0 {
+		if profiles, err = listAWSProfiles(); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, sshConfigHeader)
+	fmt.Fprintf(&buf, "# Generated on %s\n", time.Now().Format(time.RFC3339))
+
+	hostCount := 0
+	writeHost := func(alias, comment string, proxyArgs ...string) {
+		fmt.Fprintf(&buf, "\n# %s\nHost %s\n", comment, alias)
+		if opts.User != "" {
+			fmt.Fprintf(&buf, "    User %s\n", opts.User)
+		}
+		fmt.Fprintf(&buf, "    ProxyCommand %s proxy %s --port %%p\n", sshQuote(executablePath), strings.Join(proxyArgs, " "))
+		hostCount++
+	}
+
+	for _, name := range a.shortcutMgr.names() {
+		sc, _ := a.shortcutMgr.findByName(name)
+		writeHost(name, "Shortcut: "+sc.DisplayString, name)
+	}
+
+	for _, profile := range profiles {
+		region, err := resolveRegion(profile, "")
+		if err != nil {
+			fmt.Printf(Yellow("Warning: skipping profile '%s': %v\n"), profile, err)
+			continue
+		}
+		instances, err := fetchRunningInstances(profile, region)
+		if err != nil {
+			fmt.Printf(Yellow("Warning: skipping profile '%s': %v\n"), profile, err)
+			continue
+		}
+
+		nameCount := make(map[string]int)
+		for _, inst := range instances {
+			nameCount[inst.displayName()]++
+		}
+		sort.Slice(instances, func(i, j int) bool { return instances[i].displayName() < instances[j].displayName() })
+		for _, inst := range instances {
+			// Instances sharing a Name tag are addressed by ID.
+			target := inst.displayName()
+			if nameCount[target] > 1 {
+				target = inst.ID
+			}
+			alias := profile + "." + sshHostAlias(target)
+			comment := fmt.Sprintf("Profile: %s, Instance: %s (%s)", profile, inst.displayName(), inst.ID)
+			writeHost(alias, comment, sshQuote(target), "--profile", sshQuote(profile), "--region", region)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(opts.Output), 0700); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", opts.Output, err)
+	}
+	if err := writeFileAtomic(opts.Output, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", opts.Output, err)
+	}
+	fmt.Printf(Green("✅ Wrote %d Host entries to %s\n"), hostCount, opts.Output)
+
+	if opts.Include {
+		return addSSHInclude(opts.Output)
+	}
+	fmt.Printf("To use them, add this line at the top of ~/.ssh/config (or run with --include):\n    Include %s\n", opts.Output)
+	return nil
+}
+
+// sshQuote quotes an argument of a ProxyCommand.
+func sshQuote(s string) string {
+	return `"` + s + `"`
+}
+
+// sshHostAlias turns a Name tag into a valid ssh Host alias.
+func sshHostAlias(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '*' || r == '?' || r == '!' || r == '"' {
+			return '-'
+		}
+		return r
+	}, name)
+}
+
+// addSSHInclude adds an Include line for path at the top of ~/.ssh/config,
+// unless the file already includes it.
+func addSSHInclude(path string) error {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to get home directory: %w", err)
+	}
+	configPath := filepath.Join(homeDir, ".ssh", "config")
+
+	existing, err := os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", configPath, err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(existing))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && strings.EqualFold(fields[0], "Include") && includesPath(fields[1:], path, homeDir) {
+			fmt.Printf(Cyan("ℹ️  %s already includes %s\n"), configPath, path)
+			return nil
+		}
+	}
+
+	// Include must come before any Host block to apply to every host.
+	content := append([]byte(fmt.Sprintf("Include %s\n\n", path)), existing...)
+	if err := writeFileAtomic(configPath, content, 0600); err != nil {
+		return fmt.Errorf("failed to update %s: %w", configPath, err)
+	}
+	fmt.Printf(Green("✅ Added 'Include %s' to %s\n"), path, configPath)
+	return nil
+}
+
+// includesPath reports whether one of the Include arguments refers to path.
+func includesPath(args []string, path, homeDir string) bool {
+	for _, arg := range args {
+		arg = strings.Trim(arg, "\"")
+		if strings.HasPrefix(arg, "~/") {
+			arg = filepath.Join(homeDir, arg[2:])
+		} else if !filepath.IsAbs(arg) {
+			arg = filepath.Join(homeDir, ".ssh", arg)
+		}
+		if filepath.Clean(arg) == filepath.Clean(path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ssoCachedToken is an SSO token cache file, as written by 'aws sso login'
+// and 'asmago login'.
+type ssoCachedToken struct {
+	StartURL              string `json:"startUrl"`
+	Region                string `json:"region"`
+	AccessToken           string `json:"accessToken"`
+	ExpiresAt             string `json:"expiresAt"`
+	ClientID              string `json:"clientId,omitempty"`
+	ClientSecret          string `json:"clientSecret,omitempty"`
+	RegistrationExpiresAt string `json:"registrationExpiresAt,omitempty"`
+	RefreshToken          string `json:"refreshToken,omitempty"`
+}
+
+// ssoTokenCachePath returns the path of the SSO token cache file used by a
+// profile. The AWS CLI names it after the SHA-1 of the sso_session name, or of
+// the sso_start_url for profiles without an sso_session.
+func ssoTokenCachePath(ssoSession, startURL string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	key := ssoSession
+	if key == "" {
+		key = startURL
+	}
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(homeDir, ".aws", "sso", "cache", hex.EncodeToString(sum[:])+".json"), nil
+}
+
+// ssoTokenExpiry returns when the cached SSO token of a profile expires. It
+// reports ok as false for profiles that do not use SSO, and a zero time when
+// no token is cached.
+func ssoTokenExpiry(profile string) (expiresAt time.Time, ok bool, err error) {
+	cfg, err := loadProfileConfig(profile)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if !cfg.usesSSO() {
+		return time.Time{}, false, nil
+	}
+
+	path, err := ssoTokenCachePath(cfg.SSOSession, cfg.SSOStartURL)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return time.Time{}, true, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var token ssoCachedToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	expiresAt, err = parseSSOExpiry(token.ExpiresAt)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return expiresAt, true, nil
+}
+
+// parseSSOExpiry parses the expiresAt field of a token cache file. Older
+// versions of the AWS CLI wrote it with a "UTC" suffix instead of "Z".
+func parseSSOExpiry(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02T15:04:05UTC", value)
+}
+
+// ensureSSOToken refreshes the SSO token of a profile before it is used if
+// the cached token has expired or expires within window. It does nothing for
+// profiles that do not use SSO, and leaves unreadable cache files to the
+// refresh that follows a failed call.
+func ensureSSOToken(profile string, window time.Duration) error {
+	checked := time.Now()
+	expiresAt, ok, err := ssoTokenExpiry(profile)
+	if err != nil || !ok {
+		return nil
+	}
+	remaining := time.Until(expiresAt)
+	if remaining > window {
+		return nil
+	}
+
+	r := refresherFor(profile)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.refreshedAt.After(checked) {
+		return nil // Another call refreshed it meanwhile.
+	}
+
+	switch {
+	case expiresAt.IsZero():
+		fmt.Fprintf(os.Stderr, Yellow("⚠️  No SSO token found for profile '%s'. Starting to log in...\n"), profile)
+	case remaining <= 0:
+		fmt.Fprintf(os.Stderr, Yellow("⚠️  SSO token for profile '%s' has expired. Starting to refresh the token...\n"), profile)
+	default:
+		fmt.Fprintf(os.Stderr, Yellow("⚠️  SSO token for profile '%s' expires in %s. Starting to refresh the token...\n"), profile, remaining.Round(time.Second))
+	}
+	if _, err := ssoRefresh(profile); err != nil {
+		return err
+	}
+	r.refreshedAt = time.Now()
+	forgetAWSConfigs(profile)
+	return nil
+}
+
+// ssoTokenChecked records the profiles whose token was checked before their
+// first AWS API call.
+var ssoTokenChecked sync.Map
+
+// ensureSSOTokenOnce runs ensureSSOToken for a profile the first time it is
+// used in a run, refreshing only an already expired token.
+func ensureSSOTokenOnce(profile string) error {
+	if _, loaded := ssoTokenChecked.LoadOrStore(profile, true); loaded {
+		return nil
+	}
+	return ensureSSOToken(profile, 0)
+}
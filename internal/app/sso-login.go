@@ -0,0 +1,385 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	oidctypes "github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+	"github.com/lithammer/fuzzysearch/fuzzy"
+	"github.com/manifoldco/promptui"
+)
+
+// ssoDeviceGrantType is the OAuth grant type of the device authorization flow.
+const ssoDeviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// ssoRefreshGrantType is the OAuth grant type that renews a token with the
+// refresh token issued along with it.
+const ssoRefreshGrantType = "refresh_token"
+
+// ssoSessionScopes are the scopes registered for profiles using an
+// sso-session, as the AWS CLI does.
+var ssoSessionScopes = []string{"sso:account:access"}
+
+// ssoLoginTarget identifies the SSO token shared by one or more profiles.
+type ssoLoginTarget struct {
+	Session  string // Name of the sso-session, empty for legacy profiles.
+	StartURL string
+	Region   string
+}
+
+// name returns how the target is shown to the user.
+func (t ssoLoginTarget) name() string {
+	if t.Session != "" {
+		return "sso-session " + t.Session
+	}
+	return t.StartURL
+}
+
+// ssoLoginTargetFor returns the SSO token used by a profile. It reports ok as
+// false for profiles that do not use SSO.
+func ssoLoginTargetFor(profile string) (target ssoLoginTarget, ok bool, err error) {
+	c, err := loadAWSSharedConfig()
+	if err != nil {
+		return ssoLoginTarget{}, false, err
+	}
+	cfg, err := c.profile(profile)
+	if err != nil {
+		return ssoLoginTarget{}, false, err
+	}
+	if !cfg.usesSSO() {
+		return ssoLoginTarget{}, false, nil
+	}
+	if cfg.SSOSession != "" && !c.ssoSessionExists(cfg.SSOSession) {
+		return ssoLoginTarget{}, false, fmt.Errorf("profile '%s' uses sso-session '%s', which is not defined", profile, cfg.SSOSession)
+	}
+	target = ssoLoginTarget{Session: cfg.SSOSession, StartURL: cfg.SSOStartURL, Region: cfg.SSORegion}
+	if target.StartURL == "" || target.Region == "" {
+		return ssoLoginTarget{}, false, fmt.Errorf("profile '%s' needs both sso_start_url and sso_region to log in", profile)
+	}
+	return target, true, nil
+}
+
+// ssoLogin logs in to an SSO start URL with the OIDC device authorization
+// flow: the user approves a code in the browser while the token is polled for.
+// The token is written to the SSO cache in the format of the AWS CLI, so the
+// CLI and the SDK pick it up.
+func ssoLogin(target ssoLoginTarget) error {
+	ctx := context.Background()
+	client, err := newSSOOIDCClient(ctx, target.Region)
+	if err != nil {
+		return fmt.Errorf("failed to prepare SSO login: %w", err)
+	}
+
+	cachePath, err := ssoTokenCachePath(target.Session, target.StartURL)
+	if err != nil {
+		return err
+	}
+	token := readSSOCachedToken(cachePath)
+	if token.ClientID == "" || !ssoRegistrationValid(token.RegistrationExpiresAt) {
+		var scopes []string
+		if target.Session != "" {
+			scopes = ssoSessionScopes
+		}
+		reg, err := client.RegisterClient(ctx, &ssooidc.RegisterClientInput{ClientName: aws.String("asmago"), ClientType: aws.String("public"), Scopes: scopes})
+		if err != nil {
+			return fmt.Errorf("failed to register SSO client: %w", err)
+		}
+		token.ClientID = aws.ToString(reg.ClientId)
+		token.ClientSecret = aws.ToString(reg.ClientSecret)
+		token.RegistrationExpiresAt = time.Unix(reg.ClientSecretExpiresAt, 0).UTC().Format(time.RFC3339)
+	}
+
+	auth, err := client.StartDeviceAuthorization(ctx, &ssooidc.StartDeviceAuthorizationInput{
+		ClientId:     aws.String(token.ClientID),
+		ClientSecret: aws.String(token.ClientSecret),
+		StartUrl:     aws.String(target.StartURL),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start SSO device authorization: %w", err)
+	}
+
+	verificationURL := aws.ToString(auth.VerificationUriComplete)
+	if verificationURL == "" {
+		verificationURL = aws.ToString(auth.VerificationUri)
+	}
+	// Standard output may carry the output of a remote command, as with exec.
+	fmt.Fprintf(os.Stderr, Cyan("🔑 Approve the login for %s in your browser:\n"), target.name())
+	fmt.Fprintf(os.Stderr, "   %s\n", verificationURL)
+	fmt.Fprintf(os.Stderr, "   Code: %s\n", Yellow(aws.ToString(auth.UserCode)))
+	openBrowser(verificationURL)
+
+	interrupted, stop := notifyInterrupt()
+	defer stop()
+	interval := time.Duration(max(auth.Interval, 1)) * time.Second
+	deadline := time.Now().Add(time.Duration(auth.ExpiresIn) * time.Second)
+	for {
+		if !sleepUnlessInterrupted(interval, interrupted) {
+			return fmt.Errorf("login cancelled")
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the code expired before the login was approved")
+		}
+
+		output, err := client.CreateToken(ctx, &ssooidc.CreateTokenInput{
+			ClientId:     aws.String(token.ClientID),
+			ClientSecret: aws.String(token.ClientSecret),
+			GrantType:    aws.String(ssoDeviceGrantType),
+			DeviceCode:   auth.DeviceCode,
+		})
+		var pending *oidctypes.AuthorizationPendingException
+		var slowDown *oidctypes.SlowDownException
+		var denied *oidctypes.AccessDeniedException
+		switch {
+		case errors.As(err, &pending):
+			continue
+		case errors.As(err, &slowDown):
+			interval += 5 * time.Second
+			continue
+		case errors.As(err, &denied):
+			return fmt.Errorf("the login was denied")
+		case err != nil:
+			return fmt.Errorf("failed to get SSO token: %w", err)
+		}
+
+		token.StartURL = target.StartURL
+		token.Region = target.Region
+		token.AccessToken = aws.ToString(output.AccessToken)
+		token.ExpiresAt = time.Now().Add(time.Duration(output.ExpiresIn) * time.Second).UTC().Format(time.RFC3339)
+		token.RefreshToken = aws.ToString(output.RefreshToken)
+		return writeSSOCachedToken(cachePath, token)
+	}
+}
+
+// newSSOOIDCClient returns an SSO OIDC client for a region. Its calls are not
+// signed, so it needs no credentials.
+func newSSOOIDCClient(ctx context.Context, region string) (*ssooidc.Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region), config.WithCredentialsProvider(aws.AnonymousCredentials{}))
+	if err != nil {
+		return nil, err
+	}
+	return ssooidc.NewFromConfig(cfg), nil
+}
+
+// errSSORefreshUnavailable is returned by ssoRefreshToken when the cached token
+// cannot be renewed without a login.
+var errSSORefreshUnavailable = errors.New("no usable refresh token is cached")
+
+// canRefreshSSOToken reports whether a cached token can be renewed with its
+// refresh token: the cache must hold one along with a client registration
+// that has not expired at now.
+func canRefreshSSOToken(token ssoCachedToken, now time.Time) bool {
+	if token.RefreshToken == "" || token.ClientID == "" || token.ClientSecret == "" {
+		return false
+	}
+	expiresAt, err := parseSSOExpiry(token.RegistrationExpiresAt)
+	return err == nil && expiresAt.After(now)
+}
+
+// ssoRefreshToken renews the cached token of an SSO target with its refresh
+// token, as the AWS CLI and the SDK do, so no browser login is needed. It
+// returns errSSORefreshUnavailable when the cache does not allow it.
+func ssoRefreshToken(target ssoLoginTarget) error {
+	cachePath, err := ssoTokenCachePath(target.Session, target.StartURL)
+	if err != nil {
+		return err
+	}
+	token := readSSOCachedToken(cachePath)
+	if !canRefreshSSOToken(token, time.Now()) {
+		return errSSORefreshUnavailable
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), awsCallTimeout)
+	defer cancel()
+	client, err := newSSOOIDCClient(ctx, target.Region)
+	if err != nil {
+		return fmt.Errorf("failed to prepare SSO token refresh: %w", err)
+	}
+	output, err := client.CreateToken(ctx, &ssooidc.CreateTokenInput{
+		ClientId:     aws.String(token.ClientID),
+		ClientSecret: aws.String(token.ClientSecret),
+		GrantType:    aws.String(ssoRefreshGrantType),
+		RefreshToken: aws.String(token.RefreshToken),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to refresh SSO token: %w", err)
+	}
+
+	token.StartURL = target.StartURL
+	token.Region = target.Region
+	token.AccessToken = aws.ToString(output.AccessToken)
+	token.ExpiresAt = time.Now().Add(time.Duration(output.ExpiresIn) * time.Second).UTC().Format(time.RFC3339)
+	if output.RefreshToken != nil {
+		token.RefreshToken = aws.ToString(output.RefreshToken)
+	}
+	return writeSSOCachedToken(cachePath, token)
+}
+
+// readSSOCachedToken reads an SSO token cache file, returning an empty token
+// if it is missing or unreadable.
+func readSSOCachedToken(path string) ssoCachedToken {
+	var token ssoCachedToken
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &token)
+	}
+	return token
+}
+
+// writeSSOCachedToken writes an SSO token cache file readable only by the user.
+func writeSSOCachedToken(path string, token ssoCachedToken) error {
+	data, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create SSO cache directory: %w", err)
+	}
+	if err := writeFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write SSO token cache: %w", err)
+	}
+	return nil
+}
+
+// ssoRegistrationValid reports whether a cached client registration is
+// still valid for at least an hour, long enough for a login to complete.
+func ssoRegistrationValid(expiresAt string) bool {
+	t, err := parseSSOExpiry(expiresAt)
+	return err == nil && time.Until(t) > time.Hour
+}
+
+// openBrowser opens a URL in the default browser. Failures are ignored: the
+// URL is printed for the user to open by hand.
+func openBrowser(url string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if cmd.Start() == nil {
+		go cmd.Wait()
+	}
+}
+
+// Login logs in to the SSO session of a profile, or of every SSO profile when
+// all is set. Profiles sharing an sso-session or start URL log in once.
+// Without a profile, the SSO profiles are offered in a picker. For an
+// assume-role profile, it assumes the role and caches its credentials,
+// prompting for the MFA code if needed.
+func Login(profile string, all bool) error {
+	if all && profile != "" {
+		return newExitError(ExitUsage, "a profile and --all cannot be used together")
+	}
+
+	if profile != "" {
+		if err := resolveProfile(profile); err != nil {
+			return err
+		}
+		if isAssumeRoleProfile(profile) {
+			creds, err := assumeRoleCredentials(profile)
+			if err != nil {
+				return err
+			}
+			fmt.Printf(Green("✅ Role credentials of profile '%s' are cached until %s\n"), profile, creds.Expiration.Local().Format("15:04"))
+			return nil
+		}
+		target, ok, err := ssoLoginTargetFor(profile)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return newExitError(ExitUsage, "profile '%s' does not use SSO", profile)
+		}
+		return loginTo(target, []string{profile})
+	}
+
+	profilesByTarget, targets, err := ssoProfiles()
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return newExitError(ExitNotFound, "no SSO profiles found in ~/.aws/config")
+	}
+
+	if all {
+		var errs []error
+		for _, target := range targets {
+			if err := loginTo(target, profilesByTarget[target]); err != nil {
+				fmt.Fprintf(os.Stderr, Red("❌ %v\n"), err)
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) > 0 {
+			return newExitError(ExitAWS, "login failed for %d of %d SSO sessions", len(errs), len(targets))
+		}
+		return nil
+	}
+
+	var profiles []string
+	for _, target := range targets {
+		profiles = append(profiles, profilesByTarget[target]...)
+	}
+	sort.Strings(profiles)
+	prompt := promptui.Select{Label: "Select AWS Profile to log in", Items: profiles, Size: loadSettings().PageSize, Searcher: func(input string, index int) bool { return fuzzy.Match(input, profiles[index]) }}
+	_, selected, err := prompt.Run()
+	if err != nil {
+		return fmt.Errorf("selection cancelled")
+	}
+	target, _, err := ssoLoginTargetFor(selected)
+	if err != nil {
+		return err
+	}
+	return loginTo(target, profilesByTarget[target])
+}
+
+// ssoProfiles groups the SSO profiles of ~/.aws/config by the token they
+// share. Targets are returned in the order their first profile appears.
+func ssoProfiles() (map[ssoLoginTarget][]string, []ssoLoginTarget, error) {
+	profiles, err := listAWSProfiles()
+	if err != nil {
+		return nil, nil, err
+	}
+	byTarget := make(map[ssoLoginTarget][]string)
+	var targets []ssoLoginTarget
+	for _, profile := range profiles {
+		target, ok, err := ssoLoginTargetFor(profile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, Yellow("Warning: skipping profile '%s': %v\n"), profile, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if _, seen := byTarget[target]; !seen {
+			targets = append(targets, target)
+		}
+		byTarget[target] = append(byTarget[target], profile)
+	}
+	return byTarget, targets, nil
+}
+
+// loginTo logs in to one SSO target and reports the profiles it covers.
+func loginTo(target ssoLoginTarget, profiles []string) error {
+	if err := ssoLogin(target); err != nil {
+		return withExitCode(ExitAWS, fmt.Errorf("login to %s failed: %w", target.name(), err))
+	}
+	for _, profile := range profiles {
+		forgetAWSConfigs(profile)
+	}
+	fmt.Printf(Green("✅ Logged in to %s (profiles: %s)\n"), target.name(), strings.Join(profiles, ", "))
+	return nil
+}
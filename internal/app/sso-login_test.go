@@ -0,0 +1,82 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCanRefreshSSOToken(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	refreshable := ssoCachedToken{
+		AccessToken:           "access",
+		ExpiresAt:             "2026-10-17T11:00:00Z",
+		ClientID:              "client",
+		ClientSecret:          "secret",
+		RegistrationExpiresAt: "2026-12-01T00:00:00Z",
+		RefreshToken:          "refresh",
+	}
+
+	tests := []struct {
+		name  string
+		token func(ssoCachedToken) ssoCachedToken
+		want  bool
+	}{
+		{
+			name:  "refresh token and valid registration",
+			token: func(tok ssoCachedToken) ssoCachedToken { return tok },
+			want:  true,
+		},
+		{
+			name: "registration with the old expiry format",
+			token: func(tok ssoCachedToken) ssoCachedToken {
+				tok.RegistrationExpiresAt = "2026-12-01T00:00:00UTC"
+				return tok
+			},
+			want: true,
+		},
+		{
+			name: "no refresh token",
+			token: func(tok ssoCachedToken) ssoCachedToken {
+				tok.RefreshToken = ""
+				return tok
+			},
+			want: false,
+		},
+		{
+			name: "no client registration",
+			token: func(tok ssoCachedToken) ssoCachedToken {
+				tok.ClientID, tok.ClientSecret = "", ""
+				return tok
+			},
+			want: false,
+		},
+		{
+			name: "expired registration",
+			token: func(tok ssoCachedToken) ssoCachedToken {
+				tok.RegistrationExpiresAt = "2026-10-17T11:59:59Z"
+				return tok
+			},
+			want: false,
+		},
+		{
+			name: "unreadable registration expiry",
+			token: func(tok ssoCachedToken) ssoCachedToken {
+				tok.RegistrationExpiresAt = "soon"
+				return tok
+			},
+			want: false,
+		},
+		{
+			name:  "empty cache",
+			token: func(ssoCachedToken) ssoCachedToken { return ssoCachedToken{} },
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canRefreshSSOToken(tt.token(refreshable), now); got != tt.want {
+				t.Errorf("canRefreshSSOToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
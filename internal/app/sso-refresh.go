@@ -1,43 +1,34 @@
 package app
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 )
 
+// ssoRefresh renews the SSO token of a profile with the cached refresh token,
+// and logs in again to its SSO session when that is not possible or fails. It
+// reports false, without an error, for profiles that do not use SSO.
 func ssoRefresh(profile string) (bool, error) {
-	// Replicate the Bash logic to get specific configurations
-	ssoSession := getPropertyForProfile(profile, "sso_session")
-	ssoStartURL := getPropertyForProfile(profile, "sso_start_url")
-
-	// if the profile is not an SSO profile, then
-	// returns false to indicates that the process can't run any further but it's not an error
-	if ssoSession == "" && ssoStartURL == "" {
-		return false, nil
+	target, ok, err := ssoLoginTargetFor(profile)
+	if err != nil || !ok {
+		return false, err
 	}
 
-	// Run 'aws sso login' as the browser login flow is a feature of the AWS CLI
-	var loginCmd *exec.Cmd
-	if ssoSession != "" {
-		loginCmd = exec.Command("aws", "sso", "login", "--sso-session", ssoSession)
-	} else {
-		loginCmd = exec.Command("aws", "sso", "login", "--profile", profile)
+	err = ssoRefreshToken(target)
+	if err == nil {
+		fmt.Fprintln(os.Stderr, Green("🚀 SSO token for profile '"+profile+"' has been refreshed."))
+		return true, nil
+	}
+	if !errors.Is(err, errSSORefreshUnavailable) {
+		fmt.Fprintf(os.Stderr, Yellow("Warning: %v; logging in again\n"), err)
 	}
 
-	var stderr bytes.Buffer
-	loginCmd.Stdin = os.Stdin
-	loginCmd.Stdout = os.Stdout
-	loginCmd.Stderr = &stderr
-
-	if err := loginCmd.Run(); err != nil {
-		fmt.Fprintln(os.Stderr, Red("\n❌ Failed to update token for profile '"+profile+"'."))
-		fmt.Fprintln(os.Stderr, "Error Details:", stderr.String())
-		os.Exit(1)
+	if err := ssoLogin(target); err != nil {
+		return false, withExitCode(ExitAWS, fmt.Errorf("failed to update token for profile '%s': %w", profile, err))
 	}
 
-	fmt.Println(Green("\n🚀 SSO token for profile '" + profile + "' has been successfully updated!"))
+	fmt.Fprintln(os.Stderr, Green("\n🚀 SSO token for profile '"+profile+"' has been successfully updated!"))
 
 	return true, nil
 }
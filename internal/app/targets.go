@@ -0,0 +1,383 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lithammer/fuzzysearch/fuzzy"
+	"github.com/manifoldco/promptui"
+)
+
+// Target kinds. Other kinds are accepted with a warning.
+const (
+	targetRDS        = "rds"
+	targetRedis      = "redis"
+	targetOpenSearch = "opensearch"
+	targetMSK        = "msk"
+	targetHTTP       = "http"
+	targetGeneric    = "generic"
+)
+
+// targetKinds lists the known target kinds.
+var targetKinds = []string{targetRDS, targetRedis, targetOpenSearch, targetMSK, targetHTTP, targetGeneric}
+
+// Target is a remote host and port reachable through an instance, such as
+// a database, a cache, a search cluster or an internal HTTP service.
+// Entries using the rds.json schema, without a kind, are RDS targets.
+type Target struct {
+	Key       string      `json:"key"`
+	Env       string      `json:"env"`
+	Kind      string      `json:"kind"`
+	Type      string      `json:"type,omitempty"` // "read" or "write" for databases.
+	Endpoint  string      `json:"endpoint"`
+	Port      int         `json:"port"`
+	LocalPort int         `json:"local_port"`
+	Usage     usageRecord `json:"-"`
+}
+
+// ID returns the identifier used to reference the target from shortcuts and
+// usage data. RDS targets keep the "key|env|type" form of RDSConfig.
+func (t Target) ID() string {
+	if t.Kind == targetRDS {
+		return fmt.Sprintf("%s|%s|%s", t.Key, t.Env, t.Type)
+	}
+	id := fmt.Sprintf("%s:%s|%s", t.Kind, t.Key, t.Env)
+	if t.Type != "" {
+		id += "|" + t.Type
+	}
+	return id
+}
+
+// label returns a short description of the target, e.g. "cache (redis, dev)".
+func (t Target) label() string {
+	kind := t.Kind
+	if t.Type != "" {
+		kind += " " + t.Type
+	}
+	return fmt.Sprintf("%s (%s, %s)", t.Key, kind, t.Env)
+}
+
+// parseTargetID splits a target ID into its kind and key.
+func parseTargetID(id string) (kind, key string) {
+	kind = targetRDS
+	if k, rest, ok := strings.Cut(id, ":"); ok {
+		kind, id = k, rest
+	}
+	key, _, _ = strings.Cut(id, "|")
+	return kind, key
+}
+
+// rdsTarget converts an RDS configuration to a target.
+func rdsTarget(c RDSConfig) Target {
+	return Target{
+		Key:       c.Key,
+		Env:       c.Env,
+		Kind:      targetRDS,
+		Type:      c.Type,
+		Endpoint:  c.Endpoint,
+		Port:      c.Port,
+		LocalPort: c.LocalPort,
+	}
+}
+
+// targetFields lists the fields of a targets.json entry.
+var targetFields = []string{"key", "env", "kind", "type", "endpoint", "port", "local_port"}
+
+// targetsWarningsOnce reports the problems of targets.json once per run.
+var targetsWarningsOnce sync.Once
+
+// targetsConfigPath returns the path of targets.json in the configuration directory.
+func targetsConfigPath() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "targets.json"), nil
+}
+
+// loadTargetsConfig reads targets.json from the configuration directory.
+// A missing file is not an error. Entries with errors are reported and
+// skipped, like those of rds.json.
+func loadTargetsConfig() ([]Target, error) {
+	filePath, err := targetsConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	configFile, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+
+	targets, problems, err := parseTargetsConfig(configFile, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid targets.json; run 'asmago config validate' for details: %w", err)
+	}
+	targetsWarningsOnce.Do(func() {
+		skipped := false
+		for _, problem := range problems {
+			if problem.Warning {
+				fmt.Fprintf(os.Stderr, Yellow("Warning: %v\n"), problem)
+				continue
+			}
+			fmt.Fprintf(os.Stderr, Red("Error: %v\n"), problem)
+			skipped = true
+		}
+		if skipped {
+			fmt.Fprintln(os.Stderr, Red("Entries of targets.json with errors are skipped until they are fixed."))
+		}
+	})
+	return targets, nil
+}
+
+// parseTargetsConfig parses and checks the content of targets.json, the same
+// way parseRDSConfig does for rds.json. Entries without a kind are RDS
+// targets; an unknown kind is only a warning.
+func parseTargetsConfig(data []byte, path string) ([]Target, []configProblem, error) {
+	var syntaxCheck any
+	if err := json.Unmarshal(data, &syntaxCheck); err != nil {
+		return nil, nil, jsonSyntaxProblem(data, path, err)
+	}
+	if _, ok := syntaxCheck.([]any); !ok {
+		line, col := lineCol(data, skipJSONSpace(data, 0))
+		return nil, nil, configProblem{Path: path, Line: line, Col: col, Message: "expected a list of entries", Suggestion: "wrap the entries in [ and ], e.g. [{\"key\": \"sessions\", ...}]"}
+	}
+
+	positions := scanRDSEntries(data)
+	var targets []Target
+	var problems []configProblem
+	entries := make([]int, 0, len(positions)) // Entry index of each target.
+	for i, pos := range positions {
+		failed := false
+		report := func(field string, warning bool, label, message, suggestion string) {
+			line, col := pos.lineCol(data, field)
+			problems = append(problems, configProblem{Path: path, Line: line, Col: col, Entry: i + 1, Label: label, Warning: warning, Message: message, Suggestion: suggestion})
+			failed = failed || !warning
+		}
+
+		var t Target
+		err := json.Unmarshal(pos.raw, &t)
+		t.Kind = strings.ToLower(t.Kind)
+		if t.Kind == "" {
+			t.Kind = targetRDS
+		}
+		label := t.ID()
+		var badField string
+		if err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if !errors.As(err, &typeErr) || typeErr.Field == "" {
+				report("", false, "", "expected an object with "+strings.Join(targetFields, ", "), "write the entry as {\"key\": ..., \"env\": ..., ...}")
+				continue
+			}
+			badField = typeErr.Field
+			report(badField, false, label, fmt.Sprintf("%q must be a %s, not a %s", badField, jsonTypeName(typeErr.Type.Kind().String()), typeErr.Value), typeSuggestion(badField))
+		}
+
+		for _, field := range pos.order {
+			if slices.ContainsFunc(targetFields, func(f string) bool { return strings.EqualFold(f, field) }) {
+				continue
+			}
+			suggestion := "remove it; entries have " + strings.Join(targetFields, ", ")
+			if closest := closestMatch(field, targetFields, 3); closest != "" {
+				suggestion = fmt.Sprintf("did you mean %q?", closest)
+			}
+			report(field, true, label, fmt.Sprintf("unknown field %q is ignored", field), suggestion)
+		}
+
+		if !slices.Contains(targetKinds, t.Kind) && badField != "kind" {
+			suggestion := "use one of " + strings.Join(targetKinds, ", ")
+			if closest := closestMatch(t.Kind, targetKinds, 3); closest != "" {
+				suggestion = fmt.Sprintf("did you mean %q?", closest)
+			}
+			report("kind", true, label, fmt.Sprintf("unknown kind %q", t.Kind), suggestion)
+		}
+
+		for _, required := range []struct{ field, value, example string }{
+			{"key", t.Key, `"key": "sessions"`},
+			{"env", t.Env, `"env": "dev"`},
+			{"endpoint", t.Endpoint, `"endpoint": "sessions.abc123.cache.amazonaws.com"`},
+		} {
+			if strings.TrimSpace(required.value) == "" && required.field != badField {
+				report(required.field, false, label, fmt.Sprintf("%q is missing or empty", required.field), "set it, e.g. "+required.example)
+			}
+		}
+
+		if t.Kind == targetRDS && t.Type != "read" && t.Type != "write" && badField != "type" {
+			message := fmt.Sprintf("\"type\" is %q", t.Type)
+			if t.Type == "" {
+				message = "\"type\" is missing or empty"
+			}
+			report("type", false, label, message, `RDS targets need "type": "read" or "write"`)
+		}
+
+		if (t.Port < 1 || t.Port > 65535) && badField != "port" {
+			report("port", false, label, fmt.Sprintf("\"port\" %d is outside 1-65535", t.Port), "use the port of the remote host, e.g. 6379 for Redis or 443 for OpenSearch")
+		}
+		if (t.LocalPort < 1 || t.LocalPort > 65535) && badField != "local_port" {
+			report("local_port", false, label, fmt.Sprintf("\"local_port\" %d is outside 1-65535", t.LocalPort), "use a free port on your machine, e.g. 16379")
+		}
+
+		if !failed {
+			targets = append(targets, t)
+			entries = append(entries, i)
+		}
+	}
+
+	var unique []Target
+	for j, t := range targets {
+		if k := slices.IndexFunc(targets[:j], func(other Target) bool { return other.ID() == t.ID() }); k >= 0 {
+			line, col := positions[entries[j]].lineCol(data, "")
+			problems = append(problems, configProblem{Path: path, Line: line, Col: col, Entry: entries[j] + 1, Label: t.ID(), Message: fmt.Sprintf("duplicates the kind, key, env and type of entry %d", entries[k]+1), Suggestion: "change one of them, or remove the duplicate"})
+			continue
+		}
+		unique = append(unique, t)
+	}
+	slices.SortStableFunc(problems, func(a, b configProblem) int {
+		if a.Line != b.Line {
+			return a.Line - b.Line
+		}
+		return a.Col - b.Col
+	})
+	return unique, problems, nil
+}
+
+// targetsRDSConfigs returns the RDS targets among targets as RDS configurations.
+func targetsRDSConfigs(targets []Target) []RDSConfig {
+	var configs []RDSConfig
+	for _, t := range targets {
+		if t.Kind == targetRDS {
+			configs = append(configs, RDSConfig{Key: t.Key, Env: t.Env, Type: t.Type, Endpoint: t.Endpoint, Port: t.Port, LocalPort: t.LocalPort})
+		}
+	}
+	return configs
+}
+
+// loadTargets returns every target: the entries of targets.json followed by
+// the RDS targets from rds.json and RDS discovery that targets.json does not
+// already define.
+func loadTargets(profile, region string, settings Settings) ([]Target, error) {
+	targets, err := loadTargetsConfig()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, t := range targets {
+		seen[t.ID()] = true
+	}
+
+	rdsConfigs, rdsErr := loadRDSTargets(profile, region, settings)
+	for _, c := range rdsConfigs {
+		if t := rdsTarget(c); !seen[t.ID()] {
+			targets = append(targets, t)
+		}
+	}
+	if len(targets) == 0 {
+		if rdsErr != nil {
+			return nil, rdsErr
+		}
+		return nil, fmt.Errorf("no targets configured; add entries to targets.json or rds.json")
+	}
+	return targets, nil
+}
+
+// findTarget returns the target with the given ID.
+func findTarget(targets []Target, id string) (*Target, bool) {
+	for _, t := range targets {
+		if t.ID() == id {
+			return &t, true
+		}
+	}
+	return nil, false
+}
+
+// handleTargetSelection guides the user through selecting a remote host target
+// of env, or of any environment when env is empty.
+func handleTargetSelection(env, profile, region string, settings Settings) (*Target, error) {
+	allTargets, err := loadTargets(profile, region, settings)
+	if err != nil {
+		return nil, err
+	}
+	usageData, err := loadTargetUsageData()
+	if err != nil {
+		return nil, err
+	}
+	for i, t := range allTargets {
+		allTargets[i].Usage = usageData[t.ID()]
+	}
+
+	targets := filterByEnv(allTargets, env, func(t Target) string { return t.Env })
+	if len(targets) == 0 {
+		if env != "" {
+			return nil, fmt.Errorf("no targets found for env '%s'", env)
+		}
+		return nil, fmt.Errorf("no matching targets found")
+	}
+
+	sortByFrecency(targets, func(t Target) usageRecord { return t.Usage })
+	items := make([]string, len(targets))
+	for i, t := range targets {
+		items[i] = fmt.Sprintf("%s -> %s:%d", t.label(), t.Endpoint, t.Port)
+	}
+	prompt := promptui.Select{Label: "Select Remote Host", Items: items, Size: settings.PageSize, Searcher: func(input string, index int) bool { return fuzzy.Match(input, items[index]) }}
+	index, _, err := prompt.Run()
+	if err != nil {
+		return nil, nil // User cancelled
+	}
+	selected := targets[index]
+
+	record := usageData[selected.ID()]
+	record.recordUse(time.Now())
+	usageData[selected.ID()] = record
+	if err := saveTargetUsageData(usageData); err != nil {
+		fmt.Printf(Yellow("Warning: Failed to save target usage data: %v\n"), err)
+	}
+	return &selected, nil
+}
+
+func loadTargetUsageData() (map[string]usageRecord, error) {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return nil, err
+	}
+	filePath := filepath.Join(dataDir, "target_usage.json")
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make(map[string]usageRecord), nil
+		}
+		return nil, err
+	}
+	var usageMap map[string]usageRecord
+	if err := json.Unmarshal(data, &usageMap); err != nil {
+		return nil, fmt.Errorf("file %s is corrupted: %w", filePath, err)
+	}
+	return usageMap, nil
+}
+
+func saveTargetUsageData(data map[string]usageRecord) error {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return err
+	}
+
+	filePath := filepath.Join(dataDir, "target_usage.json")
+	bytes, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, bytes, 0644)
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseTargetsConfigProblems(t *testing.T) {
+	data := strings.Join([]string{
+		`[`,
+		`  {"key": "a", "env": "dev", "kind": "redis", "endpoint": "a.x", "port": 6379, "local_port": 16379},`,
+		`  {"key": "b", "env": "dev", "type": "read", "endpoint": "b.x", "port": 5432, "local_port": 15432},`,
+		`  {"key": "c", "env": "dev", "kind": "RDS", "endpoint": "c.x", "port": 5432, "local_port": 15433},`,
+		`  {"key": "d", "env": "dev", "kind": "redsi", "endpoint": "d.x", "port": 6379, "local_port": 16380},`,
+		`  {"key": "a", "env": "dev", "kind": "redis", "endpoint": "a2.x", "port": "6379", "local_port": 16381},`,
+		`  {"key": "a", "env": "dev", "kind": "Redis", "endpoint": "a3.x", "port": 6379, "local_port": 16382}`,
+		`]`,
+	}, "\n")
+
+	targets, problems, err := parseTargetsConfig([]byte(data), "targets.json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checkProblems(t, problems, []wantProblem{
+		{4, 3, 3, false, `"type" is missing`},
+		{5, 30, 4, true, `unknown kind "redsi"`},
+		{6, 67, 5, false, `"port" must be a number`},
+		{7, 3, 6, false, "duplicates the kind, key, env and type of entry 1"},
+	})
+
+	var ids []string
+	for _, target := range targets {
+		ids = append(ids, target.ID())
+	}
+	if wantIDs := []string{"redis:a|dev", "b|dev|read", "redsi:d|dev"}; !slices.Equal(ids, wantIDs) {
+		t.Errorf("got targets %v, want %v", ids, wantIDs)
+	}
+
+	var rdsIDs []string
+	for _, c := range targetsRDSConfigs(targets) {
+		rdsIDs = append(rdsIDs, c.ID())
+	}
+	if wantIDs := []string{"b|dev|read"}; !slices.Equal(rdsIDs, wantIDs) {
+		t.Errorf("got RDS targets %v, want %v", rdsIDs, wantIDs)
+	}
+}
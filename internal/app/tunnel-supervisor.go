@@ -0,0 +1,309 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tunnelReadyMarker is printed by the session-manager-plugin once a port
+// forwarding session accepts local connections.
+const tunnelReadyMarker = "Waiting for connections"
+
+// tunnelStartTimeout is how long the supervisor waits for a new tunnel to
+// become ready before reporting it as started anyway.
+const tunnelStartTimeout = 30 * time.Second
+
+// supervisorIdleTimeout is how long the supervisor keeps running without
+// any tunnel before it exits.
+const supervisorIdleTimeout = 30 * time.Second
+
+// tunnelSupervisor runs port forwarding sessions in the background and
+// serves requests on a Unix socket. It exits once it has been idle for
+// supervisorIdleTimeout.
+type tunnelSupervisor struct {
+	mu       sync.Mutex
+	tunnels  map[string]*tunnelProcess
+	starting int // Tunnels being started, not yet in tunnels.
+	nextID   int
+	listener net.Listener
+	logDir   string
+}
+
+// tunnelProcess is a running 'aws ssm start-session' child.
+type tunnelProcess struct {
+	info     TunnelInfo
+	cmd      *exec.Cmd
+	stopping bool
+	done     chan struct{}
+}
+
+// RunTunnelSupervisor runs the tunnel supervisor in the current process.
+// It is started by 'asmago connect --background' and is not meant to be
+// run by hand.
+func RunTunnelSupervisor() error {
+	socketPath, err := tunnelSocketPath()
+	if err != nil {
+		return err
+	}
+	logDir, err := tunnelLogDir()
+	if err != nil {
+		return err
+	}
+
+	if isTunnelDaemonRunning() {
+		return fmt.Errorf("tunnel supervisor is already running")
+	}
+	// A socket file left by a supervisor that crashed blocks Listen.
+	os.Remove(socketPath)
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", socketPath, err)
+	}
+	defer os.Remove(socketPath)
+	// Requests carry credentials and commands to run, so only the user may
+	// connect, whatever the umask.
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to restrict %s: %w", socketPath, err)
+	}
+
+	s := &tunnelSupervisor{tunnels: make(map[string]*tunnelProcess), listener: listener, logDir: logDir}
+	log.Printf("tunnel supervisor started (PID %d) on %s", os.Getpid(), socketPath)
+	s.scheduleIdleCheck()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				log.Printf("tunnel supervisor stopped")
+				return nil
+			}
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle serves a single request.
+func (s *tunnelSupervisor) handle(conn net.Conn) {
+	defer conn.Close()
+
+	var req tunnelRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		log.Printf("invalid request: %v", err)
+		return
+	}
+
+	var resp tunnelResponse
+	switch req.Op {
+	case tunnelOpPing:
+	case tunnelOpList:
+		resp.Tunnels = s.list()
+	case tunnelOpStart:
+		if req.Tunnel == nil {
+			resp.Error = "missing tunnel specification"
+			break
+		}
+		info, err := s.start(req.Tunnel)
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Tunnels = []TunnelInfo{*info}
+		}
+	case tunnelOpStop:
+		if err := s.stop(req.ID); err != nil {
+			resp.Error = err.Error()
+		}
+	default:
+		resp.Error = fmt.Sprintf("unknown operation '%s'", req.Op)
+	}
+
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		log.Printf("failed to send response: %v", err)
+	}
+}
+
+// scheduleIdleCheck stops the supervisor after supervisorIdleTimeout unless
+// a tunnel is running or being started by then.
+func (s *tunnelSupervisor) scheduleIdleCheck() {
+	time.AfterFunc(supervisorIdleTimeout, func() {
+		s.mu.Lock()
+		idle := len(s.tunnels) == 0 && s.starting == 0
+		s.mu.Unlock()
+		if idle {
+			s.listener.Close()
+		}
+	})
+}
+
+// list returns the active tunnels.
+func (s *tunnelSupervisor) list() []TunnelInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tunnels := make([]TunnelInfo, 0, len(s.tunnels))
+	for _, t := range s.tunnels {
+		tunnels = append(tunnels, t.info)
+	}
+	return tunnels
+}
+
+// start launches a tunnel and waits until it accepts connections, exits,
+// or tunnelStartTimeout passes.
+func (s *tunnelSupervisor) start(spec *tunnelSpec) (*TunnelInfo, error) {
+	s.mu.Lock()
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	s.starting++
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.starting--
+		s.mu.Unlock()
+		s.scheduleIdleCheck()
+	}()
+
+	logPath := filepath.Join(s.logDir, "tunnel-"+id+".log")
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tunnel log: %w", err)
+	}
+
+	cmd := exec.Command("aws", spec.Args...)
+	cmd.Env = spec.Env
+	setProcessGroup(cmd)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		logFile.Close()
+		return nil, err
+	}
+	cmd.Stderr = logFile
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		return nil, fmt.Errorf("failed to start AWS CLI: %w", err)
+	}
+
+	t := &tunnelProcess{
+		info: TunnelInfo{
+			ID:        id,
+			Shortcut:  spec.Shortcut,
+			Profile:   spec.Profile,
+			LocalPort: spec.LocalPort,
+			Remote:    spec.Remote,
+			PID:       cmd.Process.Pid,
+			StartedAt: time.Now(),
+			LogFile:   logPath,
+		},
+		cmd:  cmd,
+		done: make(chan struct{}),
+	}
+	log.Printf("tunnel %s: started %s (PID %d)", id, spec.Shortcut, t.info.PID)
+
+	// Copy the output to the log and watch for the ready marker.
+	ready := make(chan struct{})
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		scanner := bufio.NewScanner(stdout)
+		signalled := false
+		for scanner.Scan() {
+			line := scanner.Text()
+			fmt.Fprintln(logFile, line)
+			if !signalled && strings.Contains(line, tunnelReadyMarker) {
+				signalled = true
+				close(ready)
+			}
+		}
+		io.Copy(logFile, stdout)
+	}()
+
+	s.mu.Lock()
+	s.tunnels[id] = t
+	s.mu.Unlock()
+
+	go func() {
+		<-copied // All output must be read before Wait closes the pipe.
+		err := cmd.Wait()
+		logFile.Close()
+
+		s.mu.Lock()
+		delete(s.tunnels, id)
+		stopping := t.stopping
+		s.mu.Unlock()
+		close(t.done)
+
+		if stopping {
+			log.Printf("tunnel %s: stopped", id)
+		} else {
+			log.Printf("tunnel %s: exited: %v", id, err)
+		}
+		s.scheduleIdleCheck()
+	}()
+
+	select {
+	case <-ready:
+		return &t.info, nil
+	case <-t.done:
+		return nil, fmt.Errorf("session exited before accepting connections: %s", lastLines(logPath, 5))
+	case <-time.After(tunnelStartTimeout):
+		log.Printf("tunnel %s: no '%s' message after %s, assuming it is up", id, tunnelReadyMarker, tunnelStartTimeout)
+		return &t.info, nil
+	}
+}
+
+// stop terminates a tunnel and waits for it to exit.
+func (s *tunnelSupervisor) stop(id string) error {
+	s.mu.Lock()
+	t, ok := s.tunnels[id]
+	if ok {
+		t.stopping = true
+	}
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no tunnel with ID '%s'", id)
+	}
+
+	if err := killProcessTree(t.info.PID); err != nil {
+		t.cmd.Process.Kill()
+	}
+	select {
+	case <-t.done:
+	case <-time.After(10 * time.Second):
+		t.cmd.Process.Kill()
+		<-t.done
+	}
+	return nil
+}
+
+// lastLines returns the last n non-empty lines of a file, joined by " | ".
+func lastLines(path string, n int) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "no output"
+	}
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+	if len(lines) == 0 {
+		return "no output"
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, " | ")
+}
@@ -0,0 +1,299 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// Operations understood by the tunnel supervisor.
+const (
+	tunnelOpPing  = "ping"
+	tunnelOpStart = "start"
+	tunnelOpList  = "list"
+	tunnelOpStop  = "stop"
+)
+
+// TunnelInfo describes a tunnel managed by the supervisor.
+type TunnelInfo struct {
+	ID        string    `json:"id"`
+	Shortcut  string    `json:"shortcut"`
+	Profile   string    `json:"profile"`
+	LocalPort int       `json:"local_port"`
+	Remote    string    `json:"remote"`
+	PID       int       `json:"pid"`
+	StartedAt time.Time `json:"started_at"`
+	LogFile   string    `json:"log_file"`
+}
+
+// tunnelSpec is what the client sends to start a tunnel.
+type tunnelSpec struct {
+	Shortcut  string   `json:"shortcut"`
+	Profile   string   `json:"profile"`
+	LocalPort int      `json:"local_port"`
+	Remote    string   `json:"remote"`
+	Args      []string `json:"args"`
+	Env       []string `json:"env"`
+}
+
+// tunnelRequest is a single request sent over the supervisor socket.
+type tunnelRequest struct {
+	Op     string      `json:"op"`
+	ID     string      `json:"id,omitempty"`
+	Tunnel *tunnelSpec `json:"tunnel,omitempty"`
+}
+
+// tunnelResponse is the supervisor's answer to a tunnelRequest.
+type tunnelResponse struct {
+	Error   string       `json:"error,omitempty"`
+	Tunnels []TunnelInfo `json:"tunnels,omitempty"`
+}
+
+// tunnelSocketPath returns the path of the supervisor's Unix socket. It lives
+// in the log directory, which only the user can enter, since any client of
+// the socket can have the supervisor run aws with its own arguments and
+// environment.
+func tunnelSocketPath() (string, error) {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, "tunnels", "supervisor.sock"), nil
+}
+
+// sendTunnelRequest sends a request to the running supervisor.
+func sendTunnelRequest(req tunnelRequest, timeout time.Duration) (*tunnelResponse, error) {
+	socketPath, err := tunnelSocketPath()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout("unix", socketPath, 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request to tunnel supervisor: %w", err)
+	}
+	var resp tunnelResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read response from tunnel supervisor: %w", err)
+	}
+	if resp.Error != "" {
+		return &resp, fmt.Errorf("%s", resp.Error)
+	}
+	return &resp, nil
+}
+
+// isTunnelDaemonRunning reports whether a supervisor answers on the socket.
+func isTunnelDaemonRunning() bool {
+	_, err := sendTunnelRequest(tunnelRequest{Op: tunnelOpPing}, 2*time.Second)
+	return err == nil
+}
+
+// ensureTunnelDaemon starts the supervisor in the background unless it is
+// already running, and waits until it accepts requests.
+func ensureTunnelDaemon() error {
+	if isTunnelDaemonRunning() {
+		return nil
+	}
+
+	executablePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get application path: %w", err)
+	}
+	logDir, err := tunnelLogDir()
+	if err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(filepath.Join(logDir, "supervisor.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open supervisor log: %w", err)
+	}
+	defer logFile.Close()
+
+	fmt.Println(Cyan("ℹ️  Starting tunnel supervisor..."))
+	cmd := exec.Command(executablePath, "tunnels", "supervisor")
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detachProcess(cmd)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start tunnel supervisor: %w", err)
+	}
+	// The supervisor outlives this process; do not wait for it.
+	cmd.Process.Release()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if isTunnelDaemonRunning() {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("tunnel supervisor did not start; see %s", logFile.Name())
+}
+
+// tunnelLogDir returns the directory holding the supervisor's socket and
+// logs, which only the user can enter.
+func tunnelLogDir() (string, error) {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return "", err
+	}
+	logDir := filepath.Join(dataDir, "tunnels")
+	if err := os.MkdirAll(logDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create tunnel log directory: %w", err)
+	}
+	// MkdirAll leaves the mode of a directory created by an earlier version.
+	if err := os.Chmod(logDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to restrict tunnel log directory: %w", err)
+	}
+	return logDir, nil
+}
+
+// startBackgroundTunnel hands a port forwarding session to the supervisor.
+func (a *App) startBackgroundTunnel(sc *Shortcut, session *sessionCommand) error {
+	if err := ensureTunnelDaemon(); err != nil {
+		return err
+	}
+
+	name := sc.Name
+	if name == "" {
+		name = sc.DisplayString
+	}
+	if name == "" {
+		name = buildDisplayString(*sc)
+	}
+	for retryCount := 0; ; retryCount++ {
+		cmd, err := newAWSCommand(sc.Profile, session.Args)
+		if err != nil {
+			return err
+		}
+		env := cmd.Env
+		if env == nil {
+			env = os.Environ()
+		}
+		spec := &tunnelSpec{
+			Shortcut:  name,
+			Profile:   sc.Profile,
+			LocalPort: session.LocalPort,
+			Remote:    session.Remote,
+			Args:      cmd.Args[1:],
+			Env:       env,
+		}
+
+		resp, err := sendTunnelRequest(tunnelRequest{Op: tunnelOpStart, Tunnel: spec}, 2*time.Minute)
+		if err == nil && len(resp.Tunnels) == 1 {
+			t := resp.Tunnels[0]
+			fmt.Printf(Green("✅ Tunnel %s started in the background: localhost:%d -> %s (PID %d)\n"), t.ID, t.LocalPort, t.Remote, t.PID)
+			fmt.Printf("Stop it with: asmago tunnels stop %s\n", t.ID)
+			return nil
+		}
+		if err == nil {
+			return fmt.Errorf("unexpected response from tunnel supervisor")
+		}
+		failure := classifyAWSOutput(err.Error())
+		if failure != failureUnknown {
+			err = &awsError{Failure: failure, Profile: sc.Profile, Err: err}
+		}
+		if failure != failureExpiredToken || retryCount > 0 {
+			return fmt.Errorf("failed to start tunnel: %w", err)
+		}
+
+		if canRetry, refreshErr := executeRefreshProfileAction(sc.Profile); refreshErr != nil {
+			return refreshErr
+		} else if !canRetry {
+			return fmt.Errorf("failed to start tunnel: %w", err)
+		}
+		fmt.Println(Cyan("🔁 Retrying..."))
+	}
+}
+
+// ListTunnels displays the tunnels managed by the supervisor.
+func ListTunnels() error {
+	if !isTunnelDaemonRunning() {
+		fmt.Println(Yellow("No active tunnels."))
+		return nil
+	}
+	resp, err := sendTunnelRequest(tunnelRequest{Op: tunnelOpList}, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	if len(resp.Tunnels) == 0 {
+		fmt.Println(Yellow("No active tunnels."))
+		return nil
+	}
+
+	tunnels := resp.Tunnels
+	sort.Slice(tunnels, func(i, j int) bool { return tunnels[i].StartedAt.Before(tunnels[j].StartedAt) })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSHORTCUT\tLOCAL PORT\tREMOTE\tPID\tUPTIME")
+	for _, t := range tunnels {
+		uptime := time.Since(t.StartedAt).Round(time.Second)
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\t%s\n", t.ID, t.Shortcut, t.LocalPort, t.Remote, t.PID, uptime)
+	}
+	return w.Flush()
+}
+
+// StopTunnel stops the tunnel with the given ID, or every tunnel when all is true.
+func StopTunnel(id string, all bool) error {
+	if !isTunnelDaemonRunning() {
+		if all {
+			fmt.Println(Yellow("No active tunnels."))
+			return nil
+		}
+		return newExitError(ExitNotFound, "no tunnel with ID '%s'", id)
+	}
+
+	var ids []string
+	if all {
+		resp, err := sendTunnelRequest(tunnelRequest{Op: tunnelOpList}, 5*time.Second)
+		if err != nil {
+			return err
+		}
+		for _, t := range resp.Tunnels {
+			ids = append(ids, t.ID)
+		}
+	} else {
+		ids = []string{id}
+	}
+
+	for _, id := range ids {
+		if _, err := sendTunnelRequest(tunnelRequest{Op: tunnelOpStop, ID: id}, 15*time.Second); err != nil {
+			return newExitError(ExitNotFound, "failed to stop tunnel %s: %v", id, err)
+		}
+		fmt.Printf(Green("✅ Tunnel %s stopped.\n"), id)
+	}
+	return nil
+}
+
+// TunnelIDs returns the IDs of the active tunnels, for shell completion.
+func TunnelIDs() []string {
+	if !isTunnelDaemonRunning() {
+		return nil
+	}
+	resp, err := sendTunnelRequest(tunnelRequest{Op: tunnelOpList}, 2*time.Second)
+	if err != nil {
+		return nil
+	}
+	ids := make([]string, len(resp.Tunnels))
+	for i, t := range resp.Tunnels {
+		ids[i] = t.ID
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.Atoi(ids[i])
+		b, _ := strconv.Atoi(ids[j])
+		return a < b
+	})
+	return ids
+}
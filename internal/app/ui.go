@@ -2,15 +2,15 @@ package app
 
 import (
 	"fmt"
-	"sort"
 	"strings"
+	"time"
 
 	"github.com/lithammer/fuzzysearch/fuzzy"
 	"github.com/manifoldco/promptui"
 )
 
 // selectInstanceFromList prompts the user to select an EC2 instance from a list.
-func selectInstanceFromList(instances []EC2Instance) (*EC2Instance, error) {
+func selectInstanceFromList(instances []EC2Instance, settings Settings) (*EC2Instance, error) {
 	formattedItems := make([]string, len(instances))
 	for i, inst := range instances {
 		if inst.Name != nil && *inst.Name != "" {
@@ -23,7 +23,7 @@ func selectInstanceFromList(instances []EC2Instance) (*EC2Instance, error) {
 	prompt := promptui.Select{
 		Label:    "Select Instance",
 		Items:    formattedItems,
-		Size:     10,
+		Size:     settings.PageSize,
 		Searcher: func(input string, index int) bool { return fuzzy.Match(input, formattedItems[index]) },
 	}
 	index, _, err := prompt.Run()
@@ -38,7 +38,9 @@ func selectInstanceFromList(instances []EC2Instance) (*EC2Instance, error) {
 	if err != nil {
 		fmt.Println(Yellow("Warning: Failed to load instance usage data: %v", err))
 	} else {
-		usageData[selectedInstance.ID]++
+		record := usageData[selectedInstance.ID]
+		record.recordUse(time.Now())
+		usageData[selectedInstance.ID] = record
 		if err := saveInstanceUsageData(usageData); err != nil {
 			fmt.Println(Yellow("Warning: Failed to save instance usage data: %v", err))
 		}
@@ -47,9 +49,67 @@ func selectInstanceFromList(instances []EC2Instance) (*EC2Instance, error) {
 	return &selectedInstance, nil
 }
 
+// promptShortcutName asks for an optional name for a new shortcut.
+// An empty answer or a cancelled prompt leaves the shortcut unnamed.
+func promptShortcutName(sm *ShortcutManager, key string) string {
+	prompt := promptui.Prompt{
+		Label: "Name this shortcut (optional, press Enter to skip)",
+		Validate: func(input string) error {
+			if input == "" {
+				return nil
+			}
+			return sm.validateName(key, input)
+		},
+	}
+	name, err := prompt.Run()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(name)
+}
+
+// promptPortPairs asks for the instance ports to forward. It returns nil if
+// the prompt is cancelled.
+func promptPortPairs() []PortPair {
+	prompt := promptui.Prompt{
+		Label: "Ports to forward (e.g. 8080,15005:5005 as local:remote)",
+		Validate: func(input string) error {
+			_, err := parsePortPairs(input)
+			return err
+		},
+	}
+	input, err := prompt.Run()
+	if err != nil {
+		return nil
+	}
+	pairs, _ := parsePortPairs(input)
+	return pairs
+}
+
+// promptCommand asks for the shell command to run on an instance. It returns
+// an empty string if the prompt is cancelled.
+func promptCommand() string {
+	prompt := promptui.Prompt{
+		Label: "Command to run",
+		Validate: func(input string) error {
+			if strings.TrimSpace(input) == "" {
+				return fmt.Errorf("command cannot be empty")
+			}
+			return nil
+		},
+	}
+	command, err := prompt.Run()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(command)
+}
+
 // handleRDSSelection guides the user through selecting an RDS target.
-func handleRDSSelection(selectedInstance *EC2Instance) (*RDSConfig, error) {
-	allConfigs, err := loadRDSConfig()
+// Targets come from rds.json and, when enabled, from RDS discovery, and are
+// limited to env unless it is empty.
+func handleRDSSelection(env, profile, region string, settings Settings) (*RDSConfig, error) {
+	allConfigs, err := loadRDSTargets(profile, region, settings)
 	if err != nil {
 		return nil, err
 	}
@@ -58,39 +118,20 @@ func handleRDSSelection(selectedInstance *EC2Instance) (*RDSConfig, error) {
 		return nil, err
 	}
 	for i, config := range allConfigs {
-		configKey := fmt.Sprintf("%s|%s|%s", config.Key, config.Env, config.Type)
-		allConfigs[i].UsageCount = usageData[configKey]
-	}
-
-	// Filter by environment based on instance name
-	var envFilter string
-	if selectedInstance.Name != nil {
-		instanceName := *selectedInstance.Name
-		if strings.HasPrefix(instanceName, "dev") {
-			envFilter = "dev"
-			fmt.Println(Cyan("ℹ️  'dev' instance detected, showing RDS for 'dev' env..."))
-		} else if strings.HasPrefix(instanceName, "qa") {
-			envFilter = "qa"
-			fmt.Println(Cyan("ℹ️  'qa' instance detected, showing RDS for 'qa' env..."))
-		}
-	}
-	var availableConfigs []RDSConfig
-	if envFilter == "" {
-		availableConfigs = allConfigs
-	} else {
-		for _, config := range allConfigs {
-			if config.Env == envFilter {
-				availableConfigs = append(availableConfigs, config)
-			}
-		}
+		allConfigs[i].Usage = usageData[config.ID()]
 	}
+
+	availableConfigs := filterByEnv(allConfigs, env, func(config RDSConfig) string { return config.Env })
 	if len(availableConfigs) == 0 {
+		if env != "" {
+			return nil, fmt.Errorf("no RDS configurations found for env '%s'", env)
+		}
 		return nil, fmt.Errorf("no matching RDS configurations found")
 	}
 
 	// Select connection type
 	typeItems := []string{"read", "write"}
-	typePrompt := promptui.Select{Label: "Select RDS Connection Type", Items: typeItems, Searcher: func(input string, index int) bool { return fuzzy.Match(input, typeItems[index]) }}
+	typePrompt := promptui.Select{Label: "Select RDS Connection Type", Items: typeItems, Size: settings.PageSize, Searcher: func(input string, index int) bool { return fuzzy.Match(input, typeItems[index]) }}
 	_, selectedType, err := typePrompt.Run()
 	if err != nil {
 		return nil, nil // User cancelled
@@ -107,16 +148,19 @@ func handleRDSSelection(selectedInstance *EC2Instance) (*RDSConfig, error) {
 	}
 
 	// Select RDS target
-	sort.Slice(finalConfigs, func(i, j int) bool { return finalConfigs[i].UsageCount > finalConfigs[j].UsageCount })
+	sortByFrecency(finalConfigs, func(config RDSConfig) usageRecord { return config.Usage })
 	var finalKeys []string
 	for _, config := range finalConfigs {
 		displayKey := config.Key
-		if envFilter == "" {
+		if env == "" {
 			displayKey = fmt.Sprintf("%s (%s)", config.Key, config.Env)
 		}
+		if config.Discovered {
+			displayKey += " [discovered]"
+		}
 		finalKeys = append(finalKeys, displayKey)
 	}
-	keyPrompt := promptui.Select{Label: "Select RDS Target", Items: finalKeys, Searcher: func(input string, index int) bool { return fuzzy.Match(input, finalKeys[index]) }}
+	keyPrompt := promptui.Select{Label: "Select RDS Target", Items: finalKeys, Size: settings.PageSize, Searcher: func(input string, index int) bool { return fuzzy.Match(input, finalKeys[index]) }}
 	selectedIndex, _, err := keyPrompt.Run()
 	if err != nil {
 		return nil, nil // User cancelled
@@ -124,8 +168,9 @@ func handleRDSSelection(selectedInstance *EC2Instance) (*RDSConfig, error) {
 	selectedRDS := finalConfigs[selectedIndex]
 
 	// Save usage data
-	configKey := fmt.Sprintf("%s|%s|%s", selectedRDS.Key, selectedRDS.Env, selectedRDS.Type)
-	usageData[configKey]++
+	record := usageData[selectedRDS.ID()]
+	record.recordUse(time.Now())
+	usageData[selectedRDS.ID()] = record
 	if err := saveRdsUsageData(usageData); err != nil {
 		fmt.Println(Yellow("Warning: Failed to save RDS usage data: %v", err))
 	}
@@ -16,6 +16,33 @@ var version string
 // dryRun holds the state of the --dry-run flag.
 var dryRun bool
 
+// reconnect holds the state of the --reconnect flag.
+var reconnect bool
+
+// background holds the state of the --background flag of 'connect' and 'run'.
+var background bool
+
+// env holds the --env flag of the interactive session.
+var env string
+
+// connectOpts holds the flags of the 'connect' subcommand.
+var connectOpts app.ConnectOptions
+
+// execOpts holds the flags of the 'exec' subcommand.
+var execOpts app.ExecOptions
+
+// cpOpts holds the flags of the 'cp' subcommand.
+var cpOpts app.CopyOptions
+
+// proxyOpts holds the flags of the 'proxy' subcommand.
+var proxyOpts app.ProxyOptions
+
+// sshConfigOpts holds the flags of the 'ssh-config' subcommand.
+var sshConfigOpts app.SSHConfigOptions
+
+// loginAll holds the state of the --all flag of 'login'.
+var loginAll bool
+
 // rootCmd is the base command when the application is called without any subcommands.
 var rootCmd = &cobra.Command{
 	Use:   "asmago",
@@ -53,9 +80,56 @@ func Execute() {
 // init runs before main and is used to register subcommands and flags.
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&dryRun, "dry-run", "d", false, "Display the final command without executing it")
+	rootCmd.PersistentFlags().BoolVar(&reconnect, "reconnect", false, "Reconnect RDS tunnels automatically when they drop")
+
+	rootCmd.Flags().StringVar(&env, "env", "", "Offer the RDS and remote host targets of this environment instead of the detected one")
+	interactiveCmd.Flags().StringVar(&env, "env", "", "Offer the RDS and remote host targets of this environment instead of the detected one")
+
+	connectCmd.Flags().StringVarP(&connectOpts.Profile, "profile", "p", "", "AWS profile to use")
+	connectCmd.Flags().StringVarP(&connectOpts.Region, "region", "r", "", "AWS region (defaults to the profile's region)")
+	connectCmd.Flags().StringVarP(&connectOpts.Instance, "instance", "n", "", "Instance ID or Name tag of the target instance")
+	connectCmd.Flags().StringVarP(&connectOpts.Action, "action", "a", "", "Action to run: ssm, rds, forward or port")
+	connectCmd.Flags().StringVar(&connectOpts.RDS, "rds", "", "RDS target as key|env|type (or a unique key)")
+	connectCmd.Flags().StringVar(&connectOpts.Target, "target", "", "Remote host target as kind:key|env (or a unique key) for --action forward")
+	connectCmd.Flags().StringVar(&connectOpts.Ports, "ports", "", "Instance ports as port or local:remote, comma separated, for --action port")
+	connectCmd.Flags().StringVar(&connectOpts.Env, "env", "", "Only consider RDS and remote host targets of this environment")
+	connectCmd.Flags().StringVar(&connectOpts.Name, "name", "", "Save the resulting shortcut under this name")
+	connectCmd.Flags().BoolVarP(&background, "background", "b", false, "Run the RDS tunnel in the background (see 'asmago tunnels')")
+	runCmd.Flags().BoolVarP(&background, "background", "b", false, "Run the RDS tunnel in the background (see 'asmago tunnels')")
+
+	execCmd.Flags().StringVarP(&execOpts.Profile, "profile", "p", "", "AWS profile to use")
+	execCmd.Flags().StringVarP(&execOpts.Region, "region", "r", "", "AWS region (defaults to the profile's region)")
+	execCmd.Flags().StringVarP(&execOpts.Instance, "instance", "n", "", "Instance ID or Name tag of the target instance")
+	execCmd.Flags().StringArrayVarP(&execOpts.Tags, "tag", "t", nil, "Run on every instance with this tag, as Key=Value (repeatable)")
+	execCmd.Flags().IntVarP(&execOpts.Concurrency, "concurrency", "c", 10, "Number of instances to run on at once with --tag")
+
+	cpCmd.Flags().StringVarP(&cpOpts.Profile, "profile", "p", "", "AWS profile to look the host up in (not needed for shortcut names)")
+	cpCmd.Flags().StringVarP(&cpOpts.Region, "region", "r", "", "AWS region (defaults to the profile's region)")
+	cpCmd.Flags().StringVarP(&cpOpts.User, "user", "u", "", "User to log in as with scp (defaults to the one ssh picks)")
+
+	proxyCmd.Flags().StringVarP(&proxyOpts.Profile, "profile", "p", "", "AWS profile to look the host up in (not needed for shortcut names)")
+	proxyCmd.Flags().StringVarP(&proxyOpts.Region, "region", "r", "", "AWS region (defaults to the profile's region)")
+	proxyCmd.Flags().IntVar(&proxyOpts.Port, "port", 22, "SSH port on the instance")
+
+	sshConfigCmd.Flags().StringSliceVarP(&sshConfigOpts.Profiles, "profile", "p", nil, "Profiles to generate Host entries for (default: all profiles)")
+	sshConfigCmd.Flags().StringVarP(&sshConfigOpts.Output, "output", "o", app.DefaultSSHConfigPath(), "Path of the managed ssh config file")
+	sshConfigCmd.Flags().StringVarP(&sshConfigOpts.User, "user", "u", "", "User to log in as on every host")
+	sshConfigCmd.Flags().BoolVar(&sshConfigOpts.Include, "include", false, "Add an Include line for the generated file to ~/.ssh/config")
+
+	loginCmd.Flags().BoolVar(&loginAll, "all", false, "Log in to every SSO session used by a profile")
 
 	rootCmd.AddCommand(interactiveCmd)
-	rootCmd.AddCommand(listShortcutsCmd)
+	rootCmd.AddCommand(connectCmd)
+	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(shortcutsCmd)
+	rootCmd.AddCommand(rdsCmd)
+	rootCmd.AddCommand(tunnelsCmd)
+	rootCmd.AddCommand(execCmd)
+	rootCmd.AddCommand(cpCmd)
+	rootCmd.AddCommand(proxyCmd)
+	rootCmd.AddCommand(sshConfigCmd)
+	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(cleanCmd)
 }
 
@@ -69,19 +143,167 @@ var interactiveCmd = &cobra.Command{
 	},
 }
 
-// listShortcutsCmd defines the 'shortcuts' subcommand.
-var listShortcutsCmd = &cobra.Command{
-	Use:     "shortcuts",
-	Aliases: []string{"sc"},
-	Short:   "Display a list of the top 5 saved shortcuts.",
+// connectCmd defines the 'connect' subcommand.
+var connectCmd = &cobra.Command{
+	Use:   "connect",
+	Short: "Connect without prompts using flags (for scripts and CI).",
+	Long: `Resolve the profile, instance and action from flags and connect
+without opening any prompt. Missing or ambiguous values are reported
+with a non-zero exit code:
+
+  2  a required flag is missing or invalid
+  3  the profile, instance or target was not found
+  4  the instance or target is ambiguous
+  5  an AWS API call or the AWS CLI failed`,
+	Example: `  asmago connect --profile dev --instance dev-bastion --action ssm
+  asmago connect -p dev -n i-0123456789abcdef0 -a rds --rds "billing|dev|read"
+  asmago connect -p dev -n dev-bastion -a forward --target "redis:sessions|dev"
+  asmago connect -p dev -n dev-bastion -a port --ports 8080,15005:5005`,
+	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		application, err := app.NewApp(false) // dryRun is not relevant for listing
-		if err != nil {
-			log.Fatalf("❌ Failed to initialize application: %v", err)
+		exitOnError(newBackgroundApp().Connect(connectOpts))
+		if !dryRun && !background {
+			fmt.Println(app.Green("\nProcess finished."))
 		}
-		if err := application.ListShortcuts(); err != nil {
-			log.Fatalf("❌ Error displaying shortcuts: %v", err)
+	},
+}
+
+// runCmd defines the 'run' subcommand.
+var runCmd = &cobra.Command{
+	Use:   "run <name>",
+	Short: "Run a saved shortcut by its name.",
+	Long: `Run a named shortcut directly, without any menu. Shortcuts are named
+when they are created in the manual flow or with 'asmago connect --name'.`,
+	Example:           `  asmago run billing-db-read`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeShortcutNames(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(newBackgroundApp().RunShortcut(args[0]))
+		if !dryRun && !background {
+			fmt.Println(app.Green("\nProcess finished."))
+		}
+	},
+}
+
+// execCmd defines the 'exec' subcommand.
+var execCmd = &cobra.Command{
+	Use:   "exec -p <profile> (-n <instance> | -t <Key=Value>...) -- <command>",
+	Short: "Run a command on instances with SSM Run Command.",
+	Long: `Run a one-off command on an instance with SSM Run Command, without an
+interactive session. The command's output is printed once it is available,
+followed by its exit status.
+
+With --tag, the command runs on every running instance matching all the given
+tags, --concurrency at a time. Results are printed grouped by identical output.
+
+asmago exits with 0 if the command succeeded, 6 if it exited with a non-zero
+status (on any instance with --tag), or one of the codes of 'connect' if
+asmago itself failed.
+
+Linux instances run the command with AWS-RunShellScript, Windows instances
+with AWS-RunPowerShellScript. Press Ctrl+C to cancel the command.`,
+	Example: `  asmago exec -p dev -n dev-bastion -- uptime
+  asmago exec -p dev -n i-0123456789abcdef0 -- df -h
+  asmago exec -p prod -n api-1 -- 'tail -n 100 /var/log/app.log | grep ERROR'
+  asmago exec -p prod --tag Role=api --concurrency 5 -- 'systemctl status app'`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		execOpts.Command = args
+		exitOnError(newApp(dryRun).Exec(execOpts))
+	},
+}
+
+// cpCmd defines the 'cp' subcommand.
+var cpCmd = &cobra.Command{
+	Use:   "cp <source> <destination>",
+	Short: "Copy a file to or from an instance, with scp or without SSH keys.",
+	Long: `Copy a file between the local machine and an instance. The path on the
+instance is written as host:path, where the host is resolved like in 'proxy':
+as a shortcut name first, then as an instance ID or Name tag in the profile
+given with --profile.
+
+The file is copied with scp through 'asmago proxy' when scp is installed and
+an SSH key is accepted by the instance; errors of scp itself, such as a
+denied permission, are reported as they are. When ssh cannot connect or log
+in, the file is sent in base64 chunks through SSM Run Command, as root, and
+verified with its SHA-256 checksum, so neither SSH keys nor an open SSH port
+are needed; each chunk is one Run Command call, which suits config and heap
+dumps rather than very large files.`,
+	Example: `  asmago cp ./app.conf billing-db-read:/tmp/app.conf
+  asmago cp -u ec2-user ./release.tar.gz billing-db-read:/tmp/
+  asmago cp -p prod api-1:/var/log/app/heap.hprof .
+  asmago cp -p dev i-0123456789abcdef0:~/dump.sql ./dumps/`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cpOpts.Source, cpOpts.Destination = args[0], args[1]
+		exitOnError(newApp(dryRun).Copy(cpOpts))
+	},
+}
+
+// proxyCmd defines the 'proxy' subcommand.
+var proxyCmd = &cobra.Command{
+	Use:   "proxy <host>",
+	Short: "Connect stdin/stdout to an instance's SSH port (for ssh ProxyCommand).",
+	Long: `Open an SSH connection through the AWS-StartSSHSession document and
+connect it to standard input and output, for use as an OpenSSH ProxyCommand.
+
+The host is resolved as a shortcut name first, then as an instance ID or
+Name tag in the profile given with --profile.`,
+	Example: `  ssh -o ProxyCommand="asmago proxy %h --port %p" ec2-user@billing-db-read
+  ssh -o ProxyCommand="asmago proxy %h -p dev --port %p" ec2-user@dev-bastion`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeShortcutNames(1),
+	PreRun: func(cmd *cobra.Command, args []string) {
+		// Standard output carries the SSH stream, so everything printed from
+		// here on, including the first-run initialization, goes to stderr.
+		proxyOpts.Stdout = os.Stdout
+		os.Stdout = os.Stderr
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		proxyOpts.Host = args[0]
+		exitOnError(newApp(dryRun).Proxy(proxyOpts))
+	},
+}
+
+// sshConfigCmd defines the 'ssh-config' subcommand.
+var sshConfigCmd = &cobra.Command{
+	Use:   "ssh-config",
+	Short: "Write ssh Host entries for shortcuts and instances.",
+	Long: `Write a managed ssh config file with a Host entry for every named
+shortcut and for every running instance of the selected profiles, all
+using 'asmago proxy' as their ProxyCommand. Instances are named
+<profile>.<Name tag>, so 'ssh dev.dev-bastion', scp, rsync and git over
+ssh work without SSH keys being exposed to the internet.`,
+	Example: `  asmago ssh-config --profile dev --profile prod --user ec2-user --include
+  ssh dev.dev-bastion`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(newApp(false).WriteSSHConfig(sshConfigOpts))
+	},
+}
+
+// loginCmd defines the 'login' subcommand.
+var loginCmd = &cobra.Command{
+	Use:   "login [profile]",
+	Short: "Log in to AWS SSO without the AWS CLI.",
+	Long: `Log in to the SSO session of a profile with the device authorization
+flow: approve the code shown in the browser and the token is written to
+~/.aws/sso/cache, where the AWS CLI and asmago both find it.
+
+Profiles sharing an sso-session or start URL need a single login. Without
+a profile, the SSO profiles are offered in a picker.
+
+For an assume-role profile, the role is assumed and its credentials cached,
+after asking for the MFA code if the profile needs one.`,
+	Example: `  asmago login dev
+  asmago login --all`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var profile string
+		if len(args) == 1 {
+			profile = args[0]
 		}
+		exitOnError(app.Login(profile, loginAll))
 	},
 }
 
@@ -101,10 +323,8 @@ This action cannot be undone.`,
 
 // runInteractive is a helper to run the main interactive flow.
 func runInteractive() {
-	application, err := app.NewApp(dryRun)
-	if err != nil {
-		log.Fatalf("❌ Failed to initialize application: %v", err)
-	}
+	application := newApp(dryRun)
+	application.Env = env
 	if err := application.Run(); err != nil {
 		log.Fatalf("❌ Error: %v", err)
 	}
@@ -112,3 +332,33 @@ func runInteractive() {
 		fmt.Println(app.Green("\nProcess finished."))
 	}
 }
+
+// newApp initializes the application or exits on failure.
+// The --reconnect flag turns auto-reconnect on even when ASMAGO_RECONNECT is not set.
+func newApp(dryRun bool) *app.App {
+	application, err := app.NewApp(dryRun)
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize application: %v", err)
+	}
+	if reconnect {
+		application.Reconnect = true
+	}
+	return application
+}
+
+// newBackgroundApp initializes the application with the --dry-run and
+// --background flags applied.
+func newBackgroundApp() *app.App {
+	application := newApp(dryRun)
+	application.Background = background
+	return application
+}
+
+// exitOnError prints the error and exits with the code carried by it.
+func exitOnError(err error) {
+	if err == nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "❌ Error: %v\n", err)
+	os.Exit(app.ExitCode(err))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"asmago/internal/app"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rdsCmd.AddCommand(rdsListCmd)
+	rdsCmd.AddCommand(rdsAddCmd)
+	rdsCmd.AddCommand(rdsEditCmd)
+	rdsCmd.AddCommand(rdsRemoveCmd)
+}
+
+// rdsCmd defines the 'rds' subcommand. Without a subcommand it lists the
+// RDS targets, as 'rds list' does.
+var rdsCmd = &cobra.Command{
+	Use:   "rds",
+	Short: "Display and manage the RDS targets of rds.json.",
+	Long: `Display and manage the RDS targets of rds.json in the configuration directory.
+
+Targets can be referenced by their key|env|type ID, or by their key when only
+one target uses it. Every change keeps the previous file in rds.json.bak.
+
+The list includes the RDS targets of targets.json, which are changed by
+editing that file.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(newApp(false).ListRDSTargets())
+	},
+}
+
+// rdsListCmd defines the 'rds list' subcommand.
+var rdsListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "Display the RDS targets with their usage counts.",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(newApp(false).ListRDSTargets())
+	},
+}
+
+// rdsAddCmd defines the 'rds add' subcommand.
+var rdsAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add an RDS target, prompting for its fields.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(newApp(false).AddRDSTarget())
+	},
+}
+
+// rdsEditCmd defines the 'rds edit' subcommand.
+var rdsEditCmd = &cobra.Command{
+	Use:               "edit <id|key>",
+	Short:             "Change the fields of an RDS target.",
+	Long:              "Change the fields of an RDS target. When its ID changes, its usage count and the shortcuts using it follow.",
+	Example:           `  asmago rds edit "billing|dev|read"`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeRDSTargetIDs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(newApp(false).EditRDSTarget(args[0]))
+	},
+}
+
+// rdsRemoveCmd defines the 'rds rm' subcommand.
+var rdsRemoveCmd = &cobra.Command{
+	Use:               "rm <id|key>...",
+	Aliases:           []string{"remove", "delete"},
+	Short:             "Delete one or more RDS targets.",
+	Example:           `  asmago rds rm "billing|staging|read"`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeRDSTargetIDs(-1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(newApp(false).RemoveRDSTargets(args))
+	},
+}
+
+// completeRDSTargetIDs returns a completion function offering RDS target IDs
+// for the first max positional arguments. A negative max completes every argument.
+func completeRDSTargetIDs(max int) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if max >= 0 && len(args) >= max {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return app.RDSTargetIDs(), cobra.ShellCompDirectiveNoFileComp
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"asmago/internal/app"
+
+	"github.com/spf13/cobra"
+)
+
+// listAll holds the state of the 'shortcuts list --all' flag.
+var listAll bool
+
+func init() {
+	shortcutsListCmd.Flags().BoolVarP(&listAll, "all", "a", false, "List every saved shortcut instead of the top 5")
+
+	shortcutsCmd.AddCommand(shortcutsListCmd)
+	shortcutsCmd.AddCommand(shortcutsRemoveCmd)
+	shortcutsCmd.AddCommand(shortcutsRenameCmd)
+	shortcutsCmd.AddCommand(shortcutsPinCmd)
+	shortcutsCmd.AddCommand(shortcutsUnpinCmd)
+}
+
+// shortcutsCmd defines the 'shortcuts' subcommand. Without a subcommand it
+// displays the top shortcuts, as 'shortcuts list' does.
+var shortcutsCmd = &cobra.Command{
+	Use:     "shortcuts",
+	Aliases: []string{"sc"},
+	Short:   "Display and manage saved shortcuts.",
+	Long: `Display and manage saved shortcuts.
+
+Shortcuts can be referenced by name, or by their number in the output of
+'asmago shortcuts list --all'.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(newApp(false).ListShortcuts(false)) // dryRun is not relevant for listing
+	},
+}
+
+// shortcutsListCmd defines the 'shortcuts list' subcommand.
+var shortcutsListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "Display the top 5 shortcuts, or all of them with --all.",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(newApp(false).ListShortcuts(listAll))
+	},
+}
+
+// shortcutsRemoveCmd defines the 'shortcuts rm' subcommand.
+var shortcutsRemoveCmd = &cobra.Command{
+	Use:               "rm <name|number>...",
+	Aliases:           []string{"remove", "delete"},
+	Short:             "Delete one or more shortcuts.",
+	Example:           `  asmago shortcuts rm old-bastion 4`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeShortcutNames(-1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(newApp(false).RemoveShortcuts(args))
+	},
+}
+
+// shortcutsRenameCmd defines the 'shortcuts rename' subcommand.
+var shortcutsRenameCmd = &cobra.Command{
+	Use:               "rename <name|number> <new-name>",
+	Short:             "Give a shortcut a new name.",
+	Example:           `  asmago shortcuts rename 2 billing-db-read`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeShortcutNames(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(newApp(false).RenameShortcut(args[0], args[1]))
+	},
+}
+
+// shortcutsPinCmd defines the 'shortcuts pin' subcommand.
+var shortcutsPinCmd = &cobra.Command{
+	Use:               "pin <name|number>",
+	Short:             "Always show a shortcut in the picker, whatever its usage count.",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeShortcutNames(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(newApp(false).PinShortcut(args[0], true))
+	},
+}
+
+// shortcutsUnpinCmd defines the 'shortcuts unpin' subcommand.
+var shortcutsUnpinCmd = &cobra.Command{
+	Use:               "unpin <name|number>",
+	Short:             "Stop pinning a shortcut.",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeShortcutNames(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(newApp(false).PinShortcut(args[0], false))
+	},
+}
+
+// completeShortcutNames returns a completion function offering shortcut names
+// for the first max positional arguments. A negative max completes every argument.
+func completeShortcutNames(max int) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if max >= 0 && len(args) >= max {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return app.ShortcutNames(), cobra.ShellCompDirectiveNoFileComp
+	}
+}
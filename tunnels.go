@@ -0,0 +1,78 @@
+package main
+
+import (
+	"asmago/internal/app"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// stopAll holds the state of the 'tunnels stop --all' flag.
+var stopAll bool
+
+func init() {
+	tunnelsStopCmd.Flags().BoolVarP(&stopAll, "all", "a", false, "Stop every active tunnel")
+
+	tunnelsCmd.AddCommand(tunnelsListCmd)
+	tunnelsCmd.AddCommand(tunnelsStopCmd)
+	tunnelsCmd.AddCommand(tunnelsSupervisorCmd)
+}
+
+// tunnelsCmd defines the 'tunnels' subcommand.
+var tunnelsCmd = &cobra.Command{
+	Use:   "tunnels",
+	Short: "Manage port forwarding tunnels running in the background.",
+	Long: `Manage port forwarding tunnels started with 'asmago connect --background'
+or 'asmago run --background'. Background tunnels are run by a small local
+supervisor process, which exits on its own once no tunnel is left.`,
+}
+
+// tunnelsListCmd defines the 'tunnels ls' subcommand.
+var tunnelsListCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "Display the active background tunnels.",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(app.ListTunnels())
+	},
+}
+
+// tunnelsStopCmd defines the 'tunnels stop' subcommand.
+var tunnelsStopCmd = &cobra.Command{
+	Use:   "stop <id>",
+	Short: "Stop a background tunnel.",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if stopAll {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return app.TunnelIDs(), cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		var id string
+		if len(args) > 0 {
+			id = args[0]
+		}
+		exitOnError(app.StopTunnel(id, stopAll))
+	},
+}
+
+// tunnelsSupervisorCmd defines the hidden 'tunnels supervisor' subcommand,
+// which runs the supervisor process itself.
+var tunnelsSupervisorCmd = &cobra.Command{
+	Use:    "supervisor",
+	Short:  "Run the background tunnel supervisor (started automatically).",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := app.RunTunnelSupervisor(); err != nil {
+			log.Fatalf("❌ Tunnel supervisor failed: %v", err)
+		}
+	},
+}